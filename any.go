@@ -1,6 +1,7 @@
 package sonnet
 
 import (
+	"github.com/sugawarayuuta/sonnet/internal/arith"
 	"reflect"
 	"slices"
 	"strconv"
@@ -32,8 +33,8 @@ func (dec *Decoder) readAny(head byte) (any, error) {
 	if head == '[' {
 		return dec.readArrayAny()
 	}
-	if head == '"' {
-		str, err := dec.readString()
+	if dec.isQuote(head) {
+		str, err := dec.readString(head)
 		if err != nil {
 			return nil, err
 		}
@@ -53,7 +54,34 @@ func (dec *Decoder) readAny(head byte) (any, error) {
 		}
 		return nil, nil
 	}
+	if dec.isNonFinite(head) {
+		return dec.readNonFinite(head)
+	}
 	if head-'0' < 10 || head == '-' {
+		if dec.opt&optRelaxed != 0 && dec.isHex(head) {
+			dec.opt |= optKeep
+			off := dec.pos - 1 // include the head.
+			u64, neg, err := dec.readHex(head)
+			dec.opt &^= optKeep
+			if err == strconv.ErrRange {
+				return dec.hexFloat(off), nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			if dec.opt&optInt64 != 0 {
+				if i64, ok := addSign(u64, neg); ok {
+					return i64, nil
+				}
+				if !neg {
+					return u64, nil
+				}
+			}
+			if neg {
+				return -float64(u64), nil
+			}
+			return float64(u64), nil
+		}
 		if dec.opt&optNumber != 0 {
 			dec.opt |= optKeep
 			off := dec.pos - 1
@@ -67,7 +95,25 @@ func (dec *Decoder) readAny(head byte) (any, error) {
 		}
 		dec.opt |= optKeep
 		off := dec.pos
-		f64, err := dec.readFloat()
+		mant, pow, neg, exact, err := dec.readDigits()
+		if err == nil && exact && dec.opt&optInt64 != 0 {
+			if i64, ok := addSign(mant, neg); ok {
+				dec.opt &^= optKeep
+				return i64, nil
+			}
+			if !neg {
+				dec.opt &^= optKeep
+				return mant, nil
+			}
+		}
+		var f64 float64
+		if err == nil {
+			var ok bool
+			f64, ok = arith.Lemire64(mant, pow, neg)
+			if !ok {
+				err = strconv.ErrRange
+			}
+		}
 		if err == strconv.ErrRange {
 			// rare, slow path.
 			dec.pos = off
@@ -95,19 +141,16 @@ func (dec *Decoder) readObjectAny() (map[string]any, error) {
 	for {
 		dec.eatSpaces()
 		if dec.pos >= len(dec.buf) && !dec.fill() {
-			return nil, dec.errSyntax("unexpected EOF reading a byte")
+			return nil, dec.errEOF("unexpected EOF reading a byte")
 		}
 		head := dec.buf[dec.pos]
 		dec.pos++
-		if head == '}' && len(mp) == 0 {
+		if head == '}' && (len(mp) == 0 || dec.opt&optRelaxed != 0) {
 			dec.dep--
 			return mp, err
 		}
-		if head != '"' {
-			return nil, dec.errSyntax("invalid character " + strconv.QuoteRune(rune(head)) + " looking for beginning of object key string")
-		}
 
-		str, err := dec.readString()
+		str, err := dec.readKey(head)
 		if err != nil {
 			return nil, err
 		}
@@ -115,7 +158,7 @@ func (dec *Decoder) readObjectAny() (map[string]any, error) {
 
 		dec.eatSpaces()
 		if dec.pos >= len(dec.buf) && !dec.fill() {
-			return nil, dec.errSyntax("unexpected EOF reading a byte")
+			return nil, dec.errEOF("unexpected EOF reading a byte")
 		}
 		head = dec.buf[dec.pos]
 		dec.pos++
@@ -125,7 +168,7 @@ func (dec *Decoder) readObjectAny() (map[string]any, error) {
 
 		dec.eatSpaces()
 		if dec.pos >= len(dec.buf) && !dec.fill() {
-			return nil, dec.errSyntax("unexpected EOF reading a byte")
+			return nil, dec.errEOF("unexpected EOF reading a byte")
 		}
 		head = dec.buf[dec.pos]
 		dec.pos++
@@ -138,7 +181,7 @@ func (dec *Decoder) readObjectAny() (map[string]any, error) {
 
 		dec.eatSpaces()
 		if dec.pos >= len(dec.buf) && !dec.fill() {
-			return nil, dec.errSyntax("unexpected EOF reading a byte")
+			return nil, dec.errEOF("unexpected EOF reading a byte")
 		}
 		head = dec.buf[dec.pos]
 		dec.pos++
@@ -161,11 +204,11 @@ func (dec *Decoder) readArrayAny() ([]any, error) {
 	for {
 		dec.eatSpaces()
 		if dec.pos >= len(dec.buf) && !dec.fill() {
-			return nil, dec.errSyntax("unexpected EOF reading a byte")
+			return nil, dec.errEOF("unexpected EOF reading a byte")
 		}
 		head := dec.buf[dec.pos]
 		dec.pos++
-		if head == ']' && len(slice) == 0 {
+		if head == ']' && (len(slice) == 0 || dec.opt&optRelaxed != 0) {
 			dec.dep--
 			return slice, err
 		}
@@ -179,7 +222,7 @@ func (dec *Decoder) readArrayAny() ([]any, error) {
 
 		dec.eatSpaces()
 		if dec.pos >= len(dec.buf) && !dec.fill() {
-			return nil, dec.errSyntax("unexpected EOF reading a byte")
+			return nil, dec.errEOF("unexpected EOF reading a byte")
 		}
 		head = dec.buf[dec.pos]
 		dec.pos++
@@ -200,7 +243,7 @@ func appendAny(dst []byte, val any, enc *Encoder) ([]byte, error) {
 	case string:
 		return appendString(dst, val, enc.html), nil
 	case float64:
-		return appendFloat(dst, val, 64)
+		return appendFloat(dst, val, 64, enc)
 	case bool:
 		if val {
 			return append(dst, "true"...), nil
@@ -256,15 +299,17 @@ func appendObjectAny(dst []byte, val map[string]any, enc *Encoder) ([]byte, erro
 		ent.elm = elm
 		idx++
 	}
-	slices.SortFunc(srt.ents, func(fst, sec entry) int {
-		if fst.key < sec.key {
-			return -1
-		}
-		if fst.key > sec.key {
-			return 1
-		}
-		return 0
-	})
+	if !enc.opts.UnorderedMaps {
+		slices.SortFunc(srt.ents, func(fst, sec entry) int {
+			if fst.key < sec.key {
+				return -1
+			}
+			if fst.key > sec.key {
+				return 1
+			}
+			return 0
+		})
+	}
 
 	dst = append(dst, '{')
 	var mid bool
@@ -41,11 +41,11 @@ func compileArrayDecoder(typ reflect.Type) decoder {
 		for idx := 0; idx < length; idx++ {
 			dec.eatSpaces()
 			if dec.pos >= len(dec.buf) && !dec.fill() {
-				return dec.errSyntax("unexpected EOF reading a byte")
+				return dec.errEOF("unexpected EOF reading a byte")
 			}
 			head = dec.buf[dec.pos]
 			dec.pos++
-			if head == ']' && idx == 0 {
+			if head == ']' && (idx == 0 || dec.opt&optRelaxed != 0) {
 				dec.dep--
 				return nil
 			}
@@ -55,7 +55,7 @@ func compileArrayDecoder(typ reflect.Type) decoder {
 			}
 			dec.eatSpaces()
 			if dec.pos >= len(dec.buf) && !dec.fill() {
-				return dec.errSyntax("unexpected EOF reading a byte")
+				return dec.errEOF("unexpected EOF reading a byte")
 			}
 			head = dec.buf[dec.pos]
 			dec.pos++
@@ -0,0 +1,197 @@
+package sonnet
+
+import (
+	"bytes"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+)
+
+var (
+	bigInt   = reflect.TypeOf(big.Int{})
+	bigFloat = reflect.TypeOf(big.Float{})
+	bigRat   = reflect.TypeOf(big.Rat{})
+)
+
+// maxRatExp bounds the decimal exponent of the numbers turned into a big.Rat,
+// whose size grows with it. big.Rat's SetString has a similar limit.
+const maxRatExp = 1e6
+
+func isBig(typ reflect.Type) bool {
+	return typ == bigInt || typ == bigFloat || typ == bigRat
+}
+
+// readNumberText returns the number literal starting with head, or the contents
+// of the string when head is a quote. the result aliases the buffer.
+func (dec *Decoder) readNumberText(head byte, typ reflect.Type) ([]byte, bool, error) {
+	if dec.isQuote(head) {
+		str, err := dec.readString(head)
+		return str, true, err
+	}
+	if head-'0' >= 10 && head != '-' {
+		return nil, false, dec.errUnmarshalType(head, typ)
+	}
+	dec.opt |= optKeep
+	off := dec.pos - 1 // include the head.
+	err := dec.eatNumber()
+	dec.opt &^= optKeep
+	if err != nil {
+		return nil, false, err
+	}
+	return dec.buf[off:dec.pos], false, nil
+}
+
+func decodeBigInt(head byte, val reflect.Value, dec *Decoder) error {
+	const ull = "ull"
+	if head == 'n' {
+		part, err := dec.readn(len(ull))
+		if err == nil && string(part) != ull {
+			err = dec.buildErrSyntax(head, ull, part)
+		}
+		return err
+	}
+	off := dec.InputOffset()
+	src, quo, err := dec.readNumberText(head, val.Type())
+	if err != nil {
+		return err
+	}
+	i := val.Addr().Interface().(*big.Int)
+	if _, ok := i.SetString(string(src), 10); !ok {
+		desc := "number " + string(src)
+		if quo {
+			desc = "string"
+		}
+		return &UnmarshalTypeError{Value: desc, Type: val.Type(), Offset: off}
+	}
+	return nil
+}
+
+func decodeBigFloat(head byte, val reflect.Value, dec *Decoder) error {
+	const ull = "ull"
+	if head == 'n' {
+		part, err := dec.readn(len(ull))
+		if err == nil && string(part) != ull {
+			err = dec.buildErrSyntax(head, ull, part)
+		}
+		return err
+	}
+	off := dec.InputOffset()
+	src, quo, err := dec.readNumberText(head, val.Type())
+	if err != nil {
+		return err
+	}
+	flt := val.Addr().Interface().(*big.Float)
+	if flt.Prec() == 0 {
+		// enough bits to hold every digit of the literal; log2(10) < 4.
+		flt.SetPrec(uint(max(64, 4*len(src))))
+	}
+	if _, _, err := flt.Parse(string(src), 10); err != nil {
+		desc := "number " + string(src)
+		if quo {
+			desc = "string"
+		}
+		return &UnmarshalTypeError{Value: desc, Type: val.Type(), Offset: off}
+	}
+	return nil
+}
+
+func decodeBigRat(head byte, val reflect.Value, dec *Decoder) error {
+	const ull = "ull"
+	if head == 'n' {
+		part, err := dec.readn(len(ull))
+		if err == nil && string(part) != ull {
+			err = dec.buildErrSyntax(head, ull, part)
+		}
+		return err
+	}
+	off := dec.InputOffset()
+	src, quo, err := dec.readNumberText(head, val.Type())
+	if err != nil {
+		return err
+	}
+	rat := val.Addr().Interface().(*big.Rat)
+	if !quo && !ratExpOK(src) {
+		return &UnmarshalTypeError{Value: "number " + string(src), Type: val.Type(), Offset: off}
+	}
+	if _, ok := rat.SetString(string(src)); !ok {
+		desc := "number " + string(src)
+		if quo {
+			desc = "string"
+		}
+		return &UnmarshalTypeError{Value: desc, Type: val.Type(), Offset: off}
+	}
+	return nil
+}
+
+// ratExpOK reports whether the exponent of the number in src, if any,
+// is within maxRatExp.
+func ratExpOK(src []byte) bool {
+	idx := bytes.IndexAny(src, "eE")
+	if idx < 0 {
+		return true
+	}
+	var exp int
+	for _, char := range src[idx+1:] {
+		if char-'0' >= 10 {
+			continue // the sign.
+		}
+		exp = exp*10 + int(char-'0')
+		if exp > maxRatExp {
+			return false
+		}
+	}
+	return true
+}
+
+// bigAddr returns a pointer to the big number in val,
+// copying it first when val isn't addressable.
+func bigAddr[T big.Int | big.Float | big.Rat](val reflect.Value) *T {
+	if val.CanAddr() {
+		return val.Addr().Interface().(*T)
+	}
+	cpy := val.Interface().(T)
+	return &cpy
+}
+
+func encodeBigInt(dst []byte, val reflect.Value, enc *Encoder) ([]byte, error) {
+	return bigAddr[big.Int](val).Append(dst, 10), nil
+}
+
+func encodeBigFloat(dst []byte, val reflect.Value, enc *Encoder) ([]byte, error) {
+	flt := bigAddr[big.Float](val)
+	if flt.IsInf() && enc.opts.NonFinite == NonFiniteError {
+		// the string MarshalText gives, as before big.Float was a number.
+		return strconv.AppendQuote(dst, flt.Text('g', -1)), nil
+	}
+	if flt.IsInf() {
+		return appendNonFinite(dst, math.Inf(flt.Sign()), 64, enc)
+	}
+	return flt.Append(dst, 'g', -1), nil
+}
+
+func encodeBigRat(dst []byte, val reflect.Value, enc *Encoder) ([]byte, error) {
+	rat := bigAddr[big.Rat](val)
+	if rat.IsInt() {
+		return rat.Num().Append(dst, 10), nil
+	}
+	// the decimal form is finite only when the denominator is 2^m * 5^n,
+	// and then max(m, n) digits after the point are exact.
+	den := new(big.Int).Set(rat.Denom())
+	two := den.TrailingZeroBits()
+	den.Rsh(den, two)
+	var five uint
+	var rem big.Int
+	for quo, div := new(big.Int), big.NewInt(5); ; five++ {
+		quo.QuoRem(den, div, &rem)
+		if rem.Sign() != 0 {
+			break
+		}
+		den.Set(quo)
+	}
+	if den.Cmp(big.NewInt(1)) != 0 {
+		// no finite decimal form, keep the string MarshalText gives.
+		return strconv.AppendQuote(dst, rat.String()), nil
+	}
+	return append(dst, rat.FloatString(int(max(two, five)))...), nil
+}
@@ -0,0 +1,350 @@
+package sonnet
+
+import (
+	"slices"
+	"strconv"
+	"unicode/utf8"
+)
+
+type (
+	member struct {
+		key        string
+		start, end int
+	}
+)
+
+// Canonicalize returns the JSON-encoded src in the form defined by
+// RFC 8785, the JSON Canonicalization Scheme. Insignificant space is removed,
+// object keys are sorted by their UTF-16 code units, numbers are formatted
+// the way ECMAScript does, and strings use the minimal escaping.
+// Duplicate object keys and numbers that don't fit in a float64 are errors.
+func Canonicalize(src []byte) ([]byte, error) {
+	return canonicalize(nil, src)
+}
+
+func canonicalize(dst, src []byte) ([]byte, error) {
+	dec := Decoder{
+		buf: src,
+	}
+	dec.eatSpaces()
+	if dec.pos >= len(dec.buf) {
+		return nil, dec.errEOF("unexpected EOF reading a byte")
+	}
+	head := dec.buf[dec.pos]
+	dec.pos++
+	dst, err := dec.canonicalize(dst, head)
+	if err != nil {
+		return nil, err
+	}
+	dec.eatSpaces()
+	if dec.pos < len(dec.buf) {
+		return nil, dec.errSyntax("invalid character " + strconv.QuoteRune(rune(dec.buf[dec.pos])) + " after top-level value")
+	}
+	return dst, nil
+}
+
+func (dec *Decoder) canonicalize(dst []byte, head byte) ([]byte, error) {
+	if head == '{' {
+		return dec.canonicalizeObject(dst)
+	}
+	if head == '[' {
+		return dec.canonicalizeArray(dst)
+	}
+	if head == '"' {
+		str, err := dec.readString('"')
+		if err != nil {
+			return nil, err
+		}
+		return appendCanonString(dst, str), nil
+	}
+	word := keywords[head]
+	if len(word) > 0 {
+		part, err := dec.readn(len(word))
+		if err != nil {
+			return nil, err
+		}
+		if string(part) != word {
+			return nil, dec.buildErrSyntax(head, word, part)
+		}
+		dst = append(dst, head)
+		return append(dst, word...), nil
+	}
+	if head-'0' < 10 || head == '-' {
+		off := dec.pos
+		f64, err := dec.readFloat()
+		if err == strconv.ErrRange {
+			// rare, slow path.
+			dec.pos = off
+			err = dec.eatNumber()
+			if err != nil {
+				return nil, err
+			}
+			f64, err = strconv.ParseFloat(string(dec.buf[off-1:dec.pos]), 64)
+			if err != nil {
+				return nil, dec.errSyntax("number " + string(dec.buf[off-1:dec.pos]) + " is out of the float64 range")
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		return appendCanonFloat(dst, f64), nil
+	}
+	return nil, dec.errSyntax("invalid character " + strconv.QuoteRune(rune(head)) + " looking for beginning of value")
+}
+
+func (dec *Decoder) canonicalizeArray(dst []byte) ([]byte, error) {
+	err := dec.inc()
+	if err != nil {
+		return nil, err
+	}
+	dst = append(dst, '[')
+	var mid bool
+	for {
+		dec.eatSpaces()
+		if dec.pos >= len(dec.buf) {
+			return nil, dec.errEOF("unexpected EOF reading a byte")
+		}
+		head := dec.buf[dec.pos]
+		dec.pos++
+		if head == ']' && !mid {
+			dec.dep--
+			return append(dst, ']'), nil
+		}
+		if mid {
+			dst = append(dst, ',')
+		}
+
+		dst, err = dec.canonicalize(dst, head)
+		if err != nil {
+			return nil, err
+		}
+
+		dec.eatSpaces()
+		if dec.pos >= len(dec.buf) {
+			return nil, dec.errEOF("unexpected EOF reading a byte")
+		}
+		head = dec.buf[dec.pos]
+		dec.pos++
+		if head == ']' {
+			dec.dep--
+			return append(dst, ']'), nil
+		}
+		if head != ',' {
+			return nil, dec.errSyntax("invalid character " + strconv.QuoteRune(rune(head)) + " after array element")
+		}
+		mid = true
+	}
+}
+
+func (dec *Decoder) canonicalizeObject(dst []byte) ([]byte, error) {
+	err := dec.inc()
+	if err != nil {
+		return nil, err
+	}
+	// values are written to dst in the input order first,
+	// then the whole object is rebuilt in the sorted order.
+	start := len(dst)
+	var mems []member
+	for {
+		dec.eatSpaces()
+		if dec.pos >= len(dec.buf) {
+			return nil, dec.errEOF("unexpected EOF reading a byte")
+		}
+		head := dec.buf[dec.pos]
+		dec.pos++
+		if head == '}' && len(mems) == 0 {
+			dec.dep--
+			return append(dst, '{', '}'), nil
+		}
+		if head != '"' {
+			return nil, dec.errSyntax("invalid character " + strconv.QuoteRune(rune(head)) + " looking for beginning of object key string")
+		}
+
+		str, err := dec.readString('"')
+		if err != nil {
+			return nil, err
+		}
+		key := string(str)
+
+		dec.eatSpaces()
+		if dec.pos >= len(dec.buf) {
+			return nil, dec.errEOF("unexpected EOF reading a byte")
+		}
+		head = dec.buf[dec.pos]
+		dec.pos++
+		if head != ':' {
+			return nil, dec.errSyntax("invalid character " + strconv.QuoteRune(rune(head)) + " after object key")
+		}
+
+		dec.eatSpaces()
+		if dec.pos >= len(dec.buf) {
+			return nil, dec.errEOF("unexpected EOF reading a byte")
+		}
+		head = dec.buf[dec.pos]
+		dec.pos++
+
+		mbr := member{key: key, start: len(dst)}
+		dst, err = dec.canonicalize(dst, head)
+		if err != nil {
+			return nil, err
+		}
+		mbr.end = len(dst)
+		mems = append(mems, mbr)
+
+		dec.eatSpaces()
+		if dec.pos >= len(dec.buf) {
+			return nil, dec.errEOF("unexpected EOF reading a byte")
+		}
+		head = dec.buf[dec.pos]
+		dec.pos++
+		if head == '}' {
+			break
+		}
+		if head != ',' {
+			return nil, dec.errSyntax("invalid character " + strconv.QuoteRune(rune(head)) + " after object key:value pair")
+		}
+	}
+	dec.dep--
+
+	slices.SortFunc(mems, func(fst, sec member) int {
+		return compareUTF16(fst.key, sec.key)
+	})
+	vals := append([]byte(nil), dst[start:]...)
+	dst = append(dst[:start], '{')
+	for idx, mbr := range mems {
+		if idx > 0 {
+			if mems[idx-1].key == mbr.key {
+				return nil, dec.errSyntax("duplicate object key " + strconv.Quote(mbr.key))
+			}
+			dst = append(dst, ',')
+		}
+		dst = appendCanonString(dst, []byte(mbr.key))
+		dst = append(dst, ':')
+		dst = append(dst, vals[mbr.start-start:mbr.end-start]...)
+	}
+	return append(dst, '}'), nil
+}
+
+// compareUTF16 compares two strings by their UTF-16 code units,
+// which is the order RFC 8785 requires for object keys.
+func compareUTF16(fst, sec string) int {
+	for fst != "" && sec != "" {
+		run1, size1 := utf8.DecodeRuneInString(fst)
+		run2, size2 := utf8.DecodeRuneInString(sec)
+		fst, sec = fst[size1:], sec[size2:]
+		if run1 == run2 {
+			continue
+		}
+		// supplementary characters start with a high surrogate,
+		// which sorts above most, but not all, of the BMP.
+		unit1, unit2 := run1, run2
+		if run1 >= 0x10000 {
+			unit1 = 0xd800 + (run1-0x10000)>>10
+		}
+		if run2 >= 0x10000 {
+			unit2 = 0xd800 + (run2-0x10000)>>10
+		}
+		if unit1 == unit2 {
+			// both are supplementary, the low surrogates decide.
+			unit1, unit2 = run1&0x3ff, run2&0x3ff
+		}
+		if unit1 < unit2 {
+			return -1
+		}
+		return 1
+	}
+	if fst != "" {
+		return 1
+	}
+	if sec != "" {
+		return -1
+	}
+	return 0
+}
+
+func appendCanonString(dst []byte, src []byte) []byte {
+	const hex = "0123456789abcdef"
+	dst = append(dst, '"')
+	start := 0
+	for idx, char := range src {
+		if char >= ' ' && char != '"' && char != '\\' {
+			continue
+		}
+		dst = append(dst, src[start:idx]...)
+		dst = append(dst, '\\')
+		switch char {
+		case '"', '\\':
+			dst = append(dst, char)
+		case '\b':
+			dst = append(dst, 'b')
+		case '\f':
+			dst = append(dst, 'f')
+		case '\n':
+			dst = append(dst, 'n')
+		case '\r':
+			dst = append(dst, 'r')
+		case '\t':
+			dst = append(dst, 't')
+		default:
+			dst = append(dst, 'u', '0', '0', hex[char>>4], hex[char&0xf])
+		}
+		start = idx + 1
+	}
+	dst = append(dst, src[start:]...)
+	return append(dst, '"')
+}
+
+// appendCanonFloat formats f64 the way ECMAScript's Number.prototype.toString does.
+// f64 must be finite.
+func appendCanonFloat(dst []byte, f64 float64) []byte {
+	if f64 == 0 {
+		return append(dst, '0') // -0 included.
+	}
+	if f64 < 0 {
+		dst = append(dst, '-')
+		f64 = -f64
+	}
+	var buf [32]byte
+	// shortest representation in the form of d.ddde±xx.
+	sci := strconv.AppendFloat(buf[:0], f64, 'e', -1, 64)
+	var digits []byte
+	var exp int
+	for idx, char := range sci {
+		if char == 'e' {
+			exp, _ = strconv.Atoi(string(sci[idx+1:]))
+			digits = append(sci[:1:1], sci[min(2, idx):idx]...)
+			break
+		}
+	}
+	num := exp + 1 // the position of the decimal point.
+	cnt := len(digits)
+	switch {
+	case cnt <= num && num <= 21:
+		dst = append(dst, digits...)
+		for idx := cnt; idx < num; idx++ {
+			dst = append(dst, '0')
+		}
+	case 0 < num && num <= 21:
+		dst = append(dst, digits[:num]...)
+		dst = append(dst, '.')
+		dst = append(dst, digits[num:]...)
+	case -6 < num && num <= 0:
+		dst = append(dst, '0', '.')
+		for idx := num; idx < 0; idx++ {
+			dst = append(dst, '0')
+		}
+		dst = append(dst, digits...)
+	default:
+		dst = append(dst, digits[0])
+		if cnt > 1 {
+			dst = append(dst, '.')
+			dst = append(dst, digits[1:]...)
+		}
+		dst = append(dst, 'e')
+		if exp >= 0 {
+			dst = append(dst, '+')
+		}
+		dst = strconv.AppendInt(dst, int64(exp), 10)
+	}
+	return dst
+}
@@ -0,0 +1,267 @@
+// Command sonnet validates, formats and queries JSON.
+//
+// Usage:
+//
+//	sonnet validate [file ...]
+//	sonnet fmt [-c] [-indent string] [file ...]
+//	sonnet get [-c] [-indent string] pointer [file ...]
+//
+// Each file, or the standard input when there are none or the name is "-",
+// is read as a stream of JSON values, so newline-delimited JSON works as is.
+// validate reports the line and the column of the first syntax error in a file.
+// fmt writes each value indented, or compacted with -c, on its own line.
+// get writes the part of each value that the JSON pointer (RFC 6901) refers to.
+//
+// The exit status is 0 on success, 1 when an input is invalid or a pointer
+// doesn't match, and 2 on a usage error.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/sugawarayuuta/sonnet"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type (
+	// lines remembers where the lines of the current value start,
+	// so that an offset in it can be reported as a line and a column.
+	lines struct {
+		inp io.Reader
+		off int64
+		nls []int64
+		// the number of newlines dropped from nls, and the offset of the last one.
+		cnt  int
+		last int64
+	}
+	// printer writes values in the layout the flags ask for.
+	printer struct {
+		out     io.Writer
+		compact bool
+		indent  string
+		buf     bytes.Buffer
+	}
+)
+
+const usage = `usage:
+	sonnet validate [file ...]
+	sonnet fmt [-c] [-indent string] [file ...]
+	sonnet get [-c] [-indent string] pointer [file ...]
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	set := flag.NewFlagSet("sonnet "+args[0], flag.ContinueOnError)
+	set.SetOutput(stderr)
+	prt := &printer{out: stdout}
+	if args[0] != "validate" {
+		set.BoolVar(&prt.compact, "c", false, "compact instead of indenting")
+		set.StringVar(&prt.indent, "indent", "  ", "the indentation of each level")
+	}
+	if err := set.Parse(args[1:]); err != nil {
+		return 2
+	}
+	names := set.Args()
+
+	var each func(raw sonnet.RawMessage) error
+	switch args[0] {
+	case "validate":
+		each = func(raw sonnet.RawMessage) error {
+			return nil
+		}
+	case "fmt":
+		each = prt.print
+	case "get":
+		if len(names) == 0 {
+			fmt.Fprint(stderr, usage)
+			return 2
+		}
+		ptr := names[0]
+		names = names[1:]
+		if ptr != "" && ptr[0] != '/' {
+			fmt.Fprintf(stderr, "sonnet: invalid JSON pointer %q\n", ptr)
+			return 2
+		}
+		each = func(raw sonnet.RawMessage) error {
+			raw, err := lookup(raw, ptr)
+			if err != nil {
+				return err
+			}
+			return prt.print(raw)
+		}
+	default:
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	if len(names) == 0 {
+		names = []string{"-"}
+	}
+	code := 0
+	for _, name := range names {
+		inp := stdin
+		if name != "-" {
+			file, err := os.Open(name)
+			if err != nil {
+				fmt.Fprintln(stderr, "sonnet:", err)
+				code = 1
+				continue
+			}
+			inp = file
+		} else {
+			name = "<stdin>"
+		}
+		err := stream(name, inp, each)
+		if inp != stdin {
+			inp.(*os.File).Close()
+		}
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			code = 1
+		}
+	}
+	return code
+}
+
+// stream calls each for every value in inp.
+func stream(name string, inp io.Reader, each func(sonnet.RawMessage) error) error {
+	lns := &lines{inp: inp, last: -1}
+	dec := sonnet.NewDecoder(lns)
+	for {
+		var raw sonnet.RawMessage
+		off := dec.InputOffset()
+		lns.drop(off)
+		err := dec.Decode(&raw)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			var syn *sonnet.SyntaxError
+			var rde *sonnet.ReadError
+			if errors.As(err, &syn) {
+				off = syn.Offset
+			} else if errors.As(err, &rde) {
+				off = rde.Offset
+			}
+			return lns.errorAt(name, off, err)
+		}
+		if err := each(raw); err != nil {
+			return lns.errorAt(name, off+1, err)
+		}
+	}
+}
+
+func (lns *lines) Read(buf []byte) (int, error) {
+	read, err := lns.inp.Read(buf)
+	for idx := 0; idx < read; {
+		nl := bytes.IndexByte(buf[idx:read], '\n')
+		if nl < 0 {
+			break
+		}
+		idx += nl
+		lns.nls = append(lns.nls, lns.off+int64(idx))
+		idx++
+	}
+	lns.off += int64(read)
+	return read, err
+}
+
+// drop forgets the newlines that errors at off or later don't need.
+func (lns *lines) drop(off int64) {
+	idx := sort.Search(len(lns.nls), func(idx int) bool {
+		return lns.nls[idx] >= off-1
+	})
+	if idx == 0 {
+		return
+	}
+	lns.cnt += idx
+	lns.last = lns.nls[idx-1]
+	lns.nls = lns.nls[:copy(lns.nls, lns.nls[idx:])]
+}
+
+// errorAt describes err, which happened after reading off bytes, with the
+// line and the column of the last byte read, both starting from 1.
+func (lns *lines) errorAt(name string, off int64, err error) error {
+	if off > 0 {
+		off-- // the offending byte.
+	}
+	idx := sort.Search(len(lns.nls), func(idx int) bool {
+		return lns.nls[idx] >= off
+	})
+	start := lns.last + 1
+	if idx > 0 {
+		start = lns.nls[idx-1] + 1
+	}
+	msg := strings.TrimPrefix(err.Error(), "sonnet: ")
+	return fmt.Errorf("sonnet: %s:%d:%d: %s", name, lns.cnt+idx+1, off-start+1, msg)
+}
+
+func (prt *printer) print(raw sonnet.RawMessage) error {
+	prt.buf.Reset()
+	var err error
+	if prt.compact {
+		err = sonnet.Compact(&prt.buf, raw)
+	} else {
+		err = sonnet.Indent(&prt.buf, raw, "", prt.indent)
+	}
+	if err != nil {
+		return err
+	}
+	prt.buf.WriteByte('\n')
+	_, err = prt.out.Write(prt.buf.Bytes())
+	return err
+}
+
+// lookup returns the part of raw that the JSON pointer ptr refers to.
+func lookup(raw sonnet.RawMessage, ptr string) (sonnet.RawMessage, error) {
+	if ptr == "" {
+		return raw, nil
+	}
+	for _, tok := range strings.Split(ptr[1:], "/") {
+		tok = strings.ReplaceAll(tok, "~1", "/")
+		tok = strings.ReplaceAll(tok, "~0", "~")
+		raw = bytes.TrimLeft(raw, " \t\r\n")
+		if len(raw) == 0 {
+			return nil, errors.New("sonnet: empty value")
+		}
+		switch raw[0] {
+		case '{':
+			var obj map[string]sonnet.RawMessage
+			if err := sonnet.Unmarshal(raw, &obj); err != nil {
+				return nil, err
+			}
+			elm, ok := obj[tok]
+			if !ok {
+				return nil, errors.New("sonnet: no member " + strconv.Quote(tok) + " for pointer " + strconv.Quote(ptr))
+			}
+			raw = elm
+		case '[':
+			var arr []sonnet.RawMessage
+			if err := sonnet.Unmarshal(raw, &arr); err != nil {
+				return nil, err
+			}
+			idx, err := strconv.Atoi(tok)
+			// no signs and no leading zeros.
+			if err != nil || tok[0] == '+' || tok[0] == '-' || len(tok) > 1 && tok[0] == '0' || idx >= len(arr) {
+				return nil, errors.New("sonnet: no element " + strconv.Quote(tok) + " for pointer " + strconv.Quote(ptr))
+			}
+			raw = arr[idx]
+		default:
+			return nil, errors.New("sonnet: cannot look up " + strconv.Quote(tok) + " in a scalar for pointer " + strconv.Quote(ptr))
+		}
+	}
+	return raw, nil
+}
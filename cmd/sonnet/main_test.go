@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		args   []string
+		stdin  string
+		stdout string
+		stderr string
+		code   int
+	}{
+		{args: []string{"validate"}, stdin: "{\"a\": 1}\n[1, 2]\n"},
+		{args: []string{"validate"}, stdin: "{\"a\": 1}\n{\"b\": [1,\n  2,]}\n", stderr: "sonnet: <stdin>:3:5: invalid character ']' looking for beginning of value\n", code: 1},
+		{args: []string{"validate"}, stdin: "[1]]", stderr: "sonnet: <stdin>:1:4: invalid character ']' looking for beginning of value\n", code: 1},
+		{args: []string{"fmt", "-indent", "\t"}, stdin: `{"a":[1,2]} 3`, stdout: "{\n\t\"a\": [\n\t\t1,\n\t\t2\n\t]\n}\n3\n"},
+		{args: []string{"fmt", "-c"}, stdin: "{ \"a\" : 1 }\n\n[ true ]\n", stdout: "{\"a\":1}\n[true]\n"},
+		{args: []string{"get", "-c", "/a/1/b~1c"}, stdin: `{"a": [0, {"b/c": [1, 2]}]}`, stdout: "[1,2]\n"},
+		{args: []string{"get", "/a"}, stdin: "{\"a\": 1}\n{\"b\": 2}\n", stdout: "1\n", stderr: "sonnet: <stdin>:2:1: no member \"a\" for pointer \"/a\"\n", code: 1},
+		{args: []string{"get", "/01"}, stdin: `[1, 2]`, stderr: "sonnet: <stdin>:1:1: no element \"01\" for pointer \"/01\"\n", code: 1},
+		{args: []string{"get", "a"}, stderr: "sonnet: invalid JSON pointer \"a\"\n", code: 2},
+		{args: []string{"query"}, stderr: usage, code: 2},
+	}
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		code := run(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr)
+		if code != tt.code || stdout.String() != tt.stdout || stderr.String() != tt.stderr {
+			t.Errorf("run(%q) = %d, %q, %q, want %d, %q, %q", tt.args, code, stdout.String(), stderr.String(), tt.code, tt.stdout, tt.stderr)
+		}
+	}
+}
+
+func TestLinesDrop(t *testing.T) {
+	src := strings.Repeat("{\"a\": 1}\n", 1000) + "{\n\"b\": x}\n"
+	lns := &lines{inp: strings.NewReader(src), last: -1}
+	io.ReadAll(lns)
+	off := int64(strings.LastIndex(src, "{"))
+	lns.drop(off)
+	if len(lns.nls) > 3 {
+		t.Errorf("%d newlines kept, want at most 3", len(lns.nls))
+	}
+	err := lns.errorAt("in", int64(strings.Index(src, "x"))+1, errors.New("sonnet: bad"))
+	if want := "sonnet: in:1002:6: bad"; err.Error() != want {
+		t.Errorf("errorAt = %v, want %s", err, want)
+	}
+}
@@ -1,8 +1,10 @@
 package sonnet
 
 import (
+	"bytes"
 	"errors"
 	"github.com/sugawarayuuta/sonnet/internal/arith"
+	"math/big"
 	"strconv"
 )
 
@@ -12,18 +14,78 @@ type (
 		read, write int
 		dep         int
 		html        bool
+		nonFinite   bool
+		relaxed     bool
+		keepSpaces  bool
 		prefix      string
 		indent      string
+		colon       bool
+		scalars     bool
+		flat        bool
+		width       int
+		colors      *Colors
 	}
 )
 
+const (
+	colorReset = "\x1b[0m"
+)
+
+func (comp *compactor) compactAll() error {
+	comp.eatSpaces()
+	if len(comp.src) <= comp.read {
+		return comp.errSyntax("unexpected EOF reading a byte")
+	}
+	head := comp.src[comp.read]
+	comp.read++
+	err := comp.compact(head)
+	if err != nil {
+		return err
+	}
+	if comp.relaxed {
+		comp.eatSpaces()
+		if comp.read < len(comp.src) {
+			return comp.errSyntax("invalid character " + strconv.QuoteRune(rune(comp.src[comp.read])) + " after top-level value")
+		}
+	}
+	if comp.write < comp.read {
+		comp.dst = append(comp.dst, comp.src[comp.write:comp.read]...)
+	}
+	return nil
+}
+
 func (comp *compactor) compact(head byte) error {
+	if (head == '{' || head == '[') && comp.fits(head) {
+		comp.flat = true
+		err := comp.compact(head)
+		comp.flat = false
+		return err
+	}
 	if head == '{' {
 		return comp.compactObject()
 	}
 	if head == '[' {
 		return comp.compactArray()
 	}
+	if comp.colors != nil {
+		color := comp.colors.pick(head)
+		if color != "" {
+			comp.paintStart(color)
+			err := comp.compactScalar(head)
+			if err != nil {
+				return err
+			}
+			comp.paintEnd()
+			return nil
+		}
+	}
+	return comp.compactScalar(head)
+}
+
+func (comp *compactor) compactScalar(head byte) error {
+	if comp.relaxed && (head == '"' || head == '\'') {
+		return comp.standardizeString(head)
+	}
 	if head == '"' {
 		return comp.eatString()
 	}
@@ -40,6 +102,22 @@ func (comp *compactor) compact(head byte) error {
 		comp.read += len(keyword)
 		return nil
 	}
+	if comp.nonFinite {
+		// NaN, Infinity and -Infinity, written by NonFiniteLiteral.
+		off := comp.read
+		if head == '-' && off < len(comp.src) && comp.src[off] == 'I' {
+			head = comp.src[off]
+			off++
+		}
+		word := nonFinites[head]
+		if len(word) > 0 && string(comp.src[off:min(off+len(word), len(comp.src))]) == word {
+			comp.read = off + len(word)
+			return nil
+		}
+	}
+	if comp.relaxed && comp.isHex(head) {
+		return comp.standardizeHex(head)
+	}
 	if head-'0' < 10 || head == '-' {
 		return comp.eatNumber()
 	}
@@ -52,6 +130,7 @@ func (comp *compactor) compactArray() error {
 		return err
 	}
 	var mid bool
+	var comma int
 	for {
 		comp.eatSpaces()
 		if comp.read >= len(comp.src) {
@@ -63,6 +142,14 @@ func (comp *compactor) compactArray() error {
 			comp.dep--
 			return nil
 		}
+		if head == ']' && comp.relaxed {
+			comp.dropComma(comma)
+			comp.dep--
+			if comp.prefix != "" || comp.indent != "" {
+				comp.insertNewline()
+			}
+			return nil
+		}
 		if comp.prefix != "" || comp.indent != "" {
 			comp.insertNewline()
 		}
@@ -88,6 +175,12 @@ func (comp *compactor) compactArray() error {
 		if head != ',' {
 			return comp.errSyntax("invalid character " + strconv.QuoteRune(rune(head)) + " after array element")
 		}
+		if comp.flat {
+			comp.insertSpace()
+		}
+		if comp.relaxed {
+			comma = comp.flushComma()
+		}
 		mid = true
 	}
 }
@@ -98,6 +191,7 @@ func (comp *compactor) compactObject() error {
 		return err
 	}
 	var mid bool
+	var comma int
 	for {
 		comp.eatSpaces()
 		if comp.read >= len(comp.src) {
@@ -109,17 +203,34 @@ func (comp *compactor) compactObject() error {
 			comp.dep--
 			return nil
 		}
+		if head == '}' && comp.relaxed {
+			comp.dropComma(comma)
+			comp.dep--
+			if comp.prefix != "" || comp.indent != "" {
+				comp.insertNewline()
+			}
+			return nil
+		}
 		if comp.prefix != "" || comp.indent != "" {
 			comp.insertNewline()
 		}
-		if head != '"' {
+		paint := comp.colors != nil && comp.colors.Key != ""
+		if paint {
+			comp.paintStart(comp.colors.Key)
+		}
+		if comp.relaxed {
+			err = comp.standardizeKey(head)
+		} else if head != '"' {
 			return comp.errSyntax("invalid character " + strconv.QuoteRune(rune(head)) + " looking for beginning of object key string")
+		} else {
+			err = comp.eatString()
 		}
-
-		err = comp.eatString()
 		if err != nil {
 			return err
 		}
+		if paint {
+			comp.paintEnd()
+		}
 
 		comp.eatSpaces()
 		if comp.read >= len(comp.src) {
@@ -134,7 +245,7 @@ func (comp *compactor) compactObject() error {
 		if head != ':' {
 			return comp.errSyntax("invalid character " + strconv.QuoteRune(rune(head)) + " after object key")
 		}
-		if comp.prefix != "" || comp.indent != "" {
+		if comp.spaceAfterColon() {
 			comp.insertSpace()
 		}
 
@@ -166,10 +277,74 @@ func (comp *compactor) compactObject() error {
 		if head != ',' {
 			return comp.errSyntax("invalid character " + strconv.QuoteRune(rune(head)) + " after object key:value pair")
 		}
+		if comp.flat {
+			comp.insertSpace()
+		}
+		if comp.relaxed {
+			comma = comp.flushComma()
+		}
 		mid = true
 	}
 }
 
+// fits reports whether the array or object starting with head
+// should be written on one line while indenting.
+func (comp *compactor) fits(head byte) bool {
+	if comp.flat || comp.prefix == "" && comp.indent == "" {
+		return false
+	}
+	scalars := comp.scalars && head == '['
+	if comp.width <= 0 && !scalars {
+		return false
+	}
+	// the length of the one-line form, brackets included.
+	width := 1
+	dep := 1
+	for idx := comp.read; idx < len(comp.src); idx++ {
+		char := comp.src[idx]
+		switch char {
+		case ' ', '\n', '\r', '\t':
+			continue
+		case '"':
+			end := idx + 1
+			for end < len(comp.src) && comp.src[end] != '"' {
+				if comp.src[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			width += end - idx
+			idx = end
+		case '[', '{':
+			dep++
+			scalars = false
+		case ']', '}':
+			dep--
+			if dep == 0 {
+				return width+1 <= comp.width || scalars
+			}
+		case ',':
+			width++
+		case ':':
+			if comp.spaceAfterColon() {
+				width++
+			}
+		}
+		width++
+		if width > comp.width && !scalars {
+			return false
+		}
+	}
+	// truncated, let the rest of the compactor report it.
+	return false
+}
+
+// spaceAfterColon reports whether a space follows the colon after an object key,
+// which only happens when indenting.
+func (comp *compactor) spaceAfterColon() bool {
+	return comp.colon && (comp.prefix != "" || comp.indent != "")
+}
+
 func (comp *compactor) inc() error {
 	comp.dep++
 	if comp.dep > maxDep {
@@ -181,10 +356,169 @@ func (comp *compactor) inc() error {
 func (comp *compactor) eatSpaces() {
 	const spaces uint64 = 1<<' ' | 1<<'\n' | 1<<'\r' | 1<<'\t'
 	if comp.read < len(comp.src) && 1<<comp.src[comp.read]&spaces != 0 {
-		comp.dst = append(comp.dst, comp.src[comp.write:comp.read]...)
+		comp.skipSpaces()
+	}
+	if comp.relaxed {
+		comp.eatComments()
+	}
+}
+
+func (comp *compactor) skipSpaces() {
+	if comp.keepSpaces {
 		comp.eatSpacesOut()
-		comp.write = comp.read
+		return
+	}
+	comp.dst = append(comp.dst, comp.src[comp.write:comp.read]...)
+	comp.eatSpacesOut()
+	comp.write = comp.read
+}
+
+// eatComments drops // and /* */ comments, and handles the spaces after them.
+func (comp *compactor) eatComments() {
+	const spaces uint64 = 1<<' ' | 1<<'\n' | 1<<'\r' | 1<<'\t'
+	for comp.read+1 < len(comp.src) && comp.src[comp.read] == '/' {
+		end := comp.read + 2
+		switch comp.src[comp.read+1] {
+		case '/':
+			for end < len(comp.src) && comp.src[end] != '\n' {
+				end++
+			}
+		case '*':
+			idx := bytes.Index(comp.src[end:], []byte("*/"))
+			if idx < 0 {
+				return // left for the caller to reject.
+			}
+			end += idx + len("*/")
+		default:
+			return
+		}
+		comp.dst = append(comp.dst, comp.src[comp.write:comp.read]...)
+		comp.read, comp.write = end, end
+		if comp.read < len(comp.src) && 1<<comp.src[comp.read]&spaces != 0 {
+			comp.skipSpaces()
+		}
+	}
+}
+
+// flushComma writes everything up to the comma just read,
+// and returns the position of it in dst.
+func (comp *compactor) flushComma() int {
+	comp.dst = append(comp.dst, comp.src[comp.write:comp.read]...)
+	comp.write = comp.read
+	return len(comp.dst) - 1
+}
+
+// dropComma removes the trailing comma at the position comma in dst,
+// before the closing bracket just read.
+func (comp *compactor) dropComma(comma int) {
+	comp.dst = append(comp.dst, comp.src[comp.write:comp.read-1]...)
+	comp.write = comp.read - 1
+	comp.dst = append(comp.dst[:comma], comp.dst[comma+1:]...)
+}
+
+// standardizeKey writes the object key starting with head as a double-quoted string.
+func (comp *compactor) standardizeKey(head byte) error {
+	if head == '"' || head == '\'' {
+		return comp.standardizeString(head)
+	}
+	if !isIdent(head) || head-'0' < 10 {
+		return comp.errSyntax("invalid character " + strconv.QuoteRune(rune(head)) + " looking for beginning of object key string")
 	}
+	start := comp.read - 1
+	for comp.read < len(comp.src) && isIdent(comp.src[comp.read]) {
+		comp.read++
+	}
+	comp.dst = append(comp.dst, comp.src[comp.write:start]...)
+	comp.dst = append(comp.dst, '"')
+	comp.dst = append(comp.dst, comp.src[start:comp.read]...)
+	comp.dst = append(comp.dst, '"')
+	comp.write = comp.read
+	return nil
+}
+
+// standardizeString writes the string quoted with quo as a double-quoted string.
+// the \' escape, which only the relaxed mode allows, is replaced as well.
+func (comp *compactor) standardizeString(quo byte) error {
+	comp.dst = append(comp.dst, comp.src[comp.write:comp.read-1]...)
+	comp.dst = append(comp.dst, '"')
+	comp.write = comp.read
+	for comp.read < len(comp.src) {
+		char := comp.src[comp.read]
+		if char == quo {
+			comp.dst = append(comp.dst, comp.src[comp.write:comp.read]...)
+			comp.dst = append(comp.dst, '"')
+			comp.read++
+			comp.write = comp.read
+			return nil
+		}
+		if char == '"' {
+			comp.dst = append(comp.dst, comp.src[comp.write:comp.read]...)
+			comp.dst = append(comp.dst, '\\', '"')
+			comp.read++
+			comp.write = comp.read
+			continue
+		}
+		if char < ' ' {
+			return comp.errSyntax("invalid control character: " + strconv.Quote(string(char)))
+		}
+		if char != '\\' {
+			comp.read++
+			continue
+		}
+		if comp.read+1 >= len(comp.src) {
+			break
+		}
+		esc := comp.src[comp.read+1]
+		if esc == '\'' {
+			comp.dst = append(comp.dst, comp.src[comp.write:comp.read]...)
+			comp.dst = append(comp.dst, '\'')
+			comp.read += 2
+			comp.write = comp.read
+			continue
+		}
+		if replacer[esc] == 0 && esc != 'u' {
+			return comp.errSyntax("invalid escape seqence: \\" + string(esc))
+		}
+		comp.read += 2
+	}
+	return comp.errSyntax("string literal not terminated")
+}
+
+// isHex reports whether the number starting with head is hexadecimal.
+func (comp *compactor) isHex(head byte) bool {
+	off := comp.read
+	if head == '-' && off < len(comp.src) && comp.src[off] == '0' {
+		head = '0'
+		off++
+	}
+	return head == '0' && off < len(comp.src) && comp.src[off]|0x20 == 'x'
+}
+
+// standardizeHex writes the hexadecimal number starting with head in decimal.
+func (comp *compactor) standardizeHex(head byte) error {
+	start := comp.read - 1
+	comp.read++ // 'x' or 'X'
+	if head == '-' {
+		comp.read++ // '0'
+	}
+	off := comp.read
+	for comp.read < len(comp.src) && (comp.src[comp.read]-'0' < 10 || comp.src[comp.read]|0x20-'a' < 6) {
+		comp.read++
+	}
+	if off == comp.read {
+		if comp.read >= len(comp.src) {
+			return comp.errSyntax("unexpected EOF reading a hexadecimal number")
+		}
+		return comp.errSyntax("invalid character " + strconv.QuoteRune(rune(comp.src[comp.read])) + " in hexadecimal literal")
+	}
+	num, _ := new(big.Int).SetString(string(comp.src[off:comp.read]), 16)
+	comp.dst = append(comp.dst, comp.src[comp.write:start]...)
+	if head == '-' && num.Sign() != 0 {
+		comp.dst = append(comp.dst, '-')
+	}
+	comp.dst = num.Append(comp.dst, 10)
+	comp.write = comp.read
+	return nil
 }
 
 func (comp *compactor) eatSpacesOut() {
@@ -321,6 +655,9 @@ func (comp *compactor) errSyntax(msg string) error {
 }
 
 func (comp *compactor) insertNewline() {
+	if comp.flat {
+		return
+	}
 	comp.read--
 	comp.dst = append(comp.dst, comp.src[comp.write:comp.read]...)
 	comp.dst = append(comp.dst, '\n')
@@ -332,6 +669,20 @@ func (comp *compactor) insertNewline() {
 	comp.read++
 }
 
+// paintStart writes color before the token starting with the byte just read.
+func (comp *compactor) paintStart(color string) {
+	comp.dst = append(comp.dst, comp.src[comp.write:comp.read-1]...)
+	comp.dst = append(comp.dst, color...)
+	comp.write = comp.read - 1
+}
+
+// paintEnd writes the token just read followed by the reset sequence.
+func (comp *compactor) paintEnd() {
+	comp.dst = append(comp.dst, comp.src[comp.write:comp.read]...)
+	comp.dst = append(comp.dst, colorReset...)
+	comp.write = comp.read
+}
+
 func (comp *compactor) insertSpace() {
 	comp.dst = append(comp.dst, comp.src[comp.write:comp.read]...)
 	comp.dst = append(comp.dst, ' ')
@@ -40,6 +40,9 @@ type (
 	SyntaxError struct {
 		msg    string // description of error
 		Offset int64  // error occurred after reading Offset bytes
+		// Path is the JSON Pointer (RFC 6901) of the value the error occurred in,
+		// such as "/items/3". Only Validate and ValidateWithOptions set it.
+		Path string
 	}
 	// An UnmarshalTypeError describes a JSON value that was
 	// not appropriate for a value of a specific Go type.
@@ -123,6 +126,9 @@ func (err *InvalidUnmarshalError) Error() string {
 }
 
 func (err *SyntaxError) Error() string {
+	if err.Path != "" {
+		return "sonnet: " + err.msg + " at " + err.Path
+	}
 	return "sonnet: " + err.msg
 }
 
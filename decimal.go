@@ -0,0 +1,252 @@
+package sonnet
+
+import (
+	"math"
+	"math/big"
+	"math/bits"
+	"reflect"
+	"strconv"
+)
+
+// A Decimal is an exact decimal number, a coefficient times a power of ten.
+// It keeps the digits of the JSON number it was decoded from, trailing zeros
+// included, so "1.50" encodes back as "1.50". The zero value is 0.
+type Decimal struct {
+	coef uint64
+	exp  int32
+	neg  bool
+}
+
+var (
+	decimal = reflect.TypeOf(Decimal{})
+)
+
+// MakeDecimal returns the Decimal coef × 10^exp.
+func MakeDecimal(coef int64, exp int32) Decimal {
+	if coef < 0 {
+		return Decimal{coef: uint64(-coef), exp: exp, neg: true}
+	}
+	return Decimal{coef: uint64(coef), exp: exp}
+}
+
+// ParseDecimal parses str, which must be a JSON number, into a Decimal.
+// The coefficient must fit in 64 bits once trailing zeros are moved into the
+// exponent, and the exponent must fit in 32 bits; otherwise the error wraps
+// strconv.ErrRange.
+func ParseDecimal(str string) (Decimal, error) {
+	dcm, err := parseDecimal([]byte(str))
+	if err != nil {
+		return Decimal{}, &strconv.NumError{Func: "ParseDecimal", Num: str, Err: err}
+	}
+	return dcm, nil
+}
+
+func parseDecimal(src []byte) (Decimal, error) {
+	var dcm Decimal
+	if !isValidNumber(string(src)) {
+		return dcm, strconv.ErrSyntax
+	}
+	if src[0] == '-' {
+		dcm.neg = true
+		src = src[1:]
+	}
+	// zeros are held back until a non-zero digit follows, so that
+	// a coefficient too large only for its trailing zeros still fits.
+	var exp, zeros int64
+	var frac bool
+	idx := 0
+	for ; idx < len(src); idx++ {
+		char := src[idx]
+		if char == '.' {
+			frac = true
+			continue
+		}
+		if char == 'e' || char == 'E' {
+			break
+		}
+		if frac {
+			exp--
+		}
+		if char == '0' {
+			zeros++
+			continue
+		}
+		coef, ok := mulPow10(dcm.coef, zeros+1)
+		coef += uint64(char - '0')
+		if !ok || coef < uint64(char-'0') {
+			return dcm, strconv.ErrRange
+		}
+		dcm.coef, zeros = coef, 0
+	}
+	if coef, ok := mulPow10(dcm.coef, zeros); ok {
+		dcm.coef = coef
+	} else {
+		exp += zeros
+	}
+	if idx < len(src) {
+		idx++ // 'e' or 'E'
+		neg := src[idx] == '-'
+		if src[idx] == '-' || src[idx] == '+' {
+			idx++
+		}
+		var num int64
+		for ; idx < len(src); idx++ {
+			num = num*10 + int64(src[idx]-'0')
+			if num > math.MaxInt32<<1 {
+				return dcm, strconv.ErrRange
+			}
+		}
+		if neg {
+			num = -num
+		}
+		exp += num
+	}
+	if exp < math.MinInt32 || exp > math.MaxInt32 {
+		return dcm, strconv.ErrRange
+	}
+	dcm.exp = int32(exp)
+	return dcm, nil
+}
+
+// mulPow10 returns u64 × 10^cnt, reporting whether it fits in a uint64.
+func mulPow10(u64 uint64, cnt int64) (uint64, bool) {
+	for ; cnt > 0 && u64 != 0; cnt-- {
+		hi, lo := bits.Mul64(u64, 10)
+		if hi != 0 {
+			return 0, false
+		}
+		u64 = lo
+	}
+	return u64, true
+}
+
+// String returns the decimal as a JSON number. It uses plain notation when the
+// exponent is not positive and the number isn't too small, like "1.50" and
+// "0.001", and scientific notation otherwise, like "1.5e+3".
+func (dcm Decimal) String() string {
+	return string(dcm.append(nil))
+}
+
+func (dcm Decimal) append(dst []byte) []byte {
+	if dcm.neg {
+		dst = append(dst, '-')
+	}
+	var buf [20]byte
+	digits := strconv.AppendUint(buf[:0], dcm.coef, 10)
+	adj := int(dcm.exp) + len(digits) - 1
+	if dcm.exp <= 0 && adj >= -6 {
+		// the number of digits before the point.
+		pnt := len(digits) + int(dcm.exp)
+		if pnt <= 0 {
+			dst = append(dst, '0')
+		} else {
+			dst = append(dst, digits[:pnt]...)
+		}
+		if dcm.exp == 0 {
+			return dst
+		}
+		dst = append(dst, '.')
+		for ; pnt < 0; pnt++ {
+			dst = append(dst, '0')
+		}
+		return append(dst, digits[pnt:]...)
+	}
+	dst = append(dst, digits[0])
+	if len(digits) > 1 {
+		dst = append(dst, '.')
+		dst = append(dst, digits[1:]...)
+	}
+	dst = append(dst, 'e')
+	if adj >= 0 {
+		dst = append(dst, '+')
+	}
+	return strconv.AppendInt(dst, int64(adj), 10)
+}
+
+// Int64 returns the decimal as an int64. It fails with strconv.ErrSyntax
+// when the decimal has a fractional part and with strconv.ErrRange when
+// it doesn't fit.
+func (dcm Decimal) Int64() (int64, error) {
+	coef := dcm.coef
+	if dcm.exp >= 0 {
+		var ok bool
+		coef, ok = mulPow10(coef, int64(dcm.exp))
+		if !ok {
+			return 0, &strconv.NumError{Func: "Int64", Num: dcm.String(), Err: strconv.ErrRange}
+		}
+	}
+	for cnt := dcm.exp; cnt < 0 && coef != 0; cnt++ {
+		if coef%10 != 0 {
+			return 0, &strconv.NumError{Func: "Int64", Num: dcm.String(), Err: strconv.ErrSyntax}
+		}
+		coef /= 10
+	}
+	if dcm.neg {
+		if coef > 1<<63 {
+			return 0, &strconv.NumError{Func: "Int64", Num: dcm.String(), Err: strconv.ErrRange}
+		}
+		return -int64(coef), nil
+	}
+	if coef > math.MaxInt64 {
+		return 0, &strconv.NumError{Func: "Int64", Num: dcm.String(), Err: strconv.ErrRange}
+	}
+	return int64(coef), nil
+}
+
+// Float64 returns the float64 nearest to the decimal.
+func (dcm Decimal) Float64() (float64, error) {
+	return strconv.ParseFloat(dcm.String(), 64)
+}
+
+// Rat returns the decimal as an exact big.Rat. It fails with
+// strconv.ErrRange when the exponent is beyond ±1e6, as the result would
+// take too much memory.
+func (dcm Decimal) Rat() (*big.Rat, error) {
+	if dcm.exp > maxRatExp || dcm.exp < -maxRatExp {
+		return nil, &strconv.NumError{Func: "Rat", Num: dcm.String(), Err: strconv.ErrRange}
+	}
+	num := new(big.Int).SetUint64(dcm.coef)
+	if dcm.neg {
+		num.Neg(num)
+	}
+	pow := big.NewInt(10)
+	if dcm.exp >= 0 {
+		pow.Exp(pow, big.NewInt(int64(dcm.exp)), nil)
+		return new(big.Rat).SetInt(num.Mul(num, pow)), nil
+	}
+	pow.Exp(pow, big.NewInt(-int64(dcm.exp)), nil)
+	return new(big.Rat).SetFrac(num, pow), nil
+}
+
+func decodeDecimal(head byte, val reflect.Value, dec *Decoder) error {
+	const ull = "ull"
+	if head == 'n' {
+		part, err := dec.readn(len(ull))
+		if err == nil && string(part) != ull {
+			err = dec.buildErrSyntax(head, ull, part)
+		}
+		return err
+	}
+	off := dec.InputOffset()
+	src, quo, err := dec.readNumberText(head, val.Type())
+	if err != nil {
+		return err
+	}
+	dcm, err := parseDecimal(src)
+	if err != nil {
+		desc := "number " + string(src)
+		if quo {
+			desc = "string"
+		}
+		return &UnmarshalTypeError{Value: desc, Type: val.Type(), Offset: off}
+	}
+	val.Set(reflect.ValueOf(dcm))
+	return nil
+}
+
+func encodeDecimal(dst []byte, val reflect.Value, enc *Encoder) ([]byte, error) {
+	if val.CanAddr() {
+		return val.Addr().Interface().(*Decimal).append(dst), nil
+	}
+	return val.Interface().(Decimal).append(dst), nil
+}
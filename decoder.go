@@ -34,6 +34,48 @@ func NewDecoder(inp io.Reader) *Decoder {
 	}
 }
 
+// NewDecoderSize is like NewDecoder but starts with a buffer of at least size bytes.
+// The buffer still grows when a single value doesn't fit in it.
+// Sizes below 1024 are rounded up to it.
+func NewDecoderSize(inp io.Reader, size int) *Decoder {
+	return &Decoder{
+		buf: mem.Get(max(size, 1<<10))[:0],
+		inp: inp,
+	}
+}
+
+// Reset discards the state of the decoder and makes it read from inp,
+// so that one decoder can serve many streams. The buffer and the options,
+// such as UseNumber and DisallowUnknownFields, are kept.
+// A released decoder gets a new buffer.
+func (dec *Decoder) Reset(inp io.Reader) {
+	if dec.buf == nil {
+		dec.buf = mem.Get(1 << 10)
+	}
+	dec.buf = dec.buf[:0]
+	dec.pos, dec.prev = 0, 0
+	dec.dep = 0
+	dec.state, dec.stack = tokenTopValue, dec.stack[:0]
+	dec.inp = inp
+	dec.err = nil
+	dec.opt &^= optKeep
+	if dec.tr != nil {
+		*dec.tr = transcoder{raw: dec.tr.raw[:0]}
+	}
+}
+
+// Release returns the buffer of the decoder to the pool it came from.
+// The decoder, and any reader returned by Buffered, must not be used
+// afterwards unless Reset is called first.
+func (dec *Decoder) Release() {
+	if dec.buf != nil {
+		mem.Put(dec.buf)
+	}
+	dec.buf, dec.sub = nil, nil
+	dec.pos, dec.prev = 0, 0
+	dec.inp = nil
+}
+
 // Buffered returns a reader of the data remaining in the Decoder's
 // buffer. The reader is valid until the next call to Decode.
 func (dec *Decoder) Buffered() io.Reader {
@@ -41,7 +83,9 @@ func (dec *Decoder) Buffered() io.Reader {
 }
 
 // More reports whether there is another element in the
-// current array or object being parsed.
+// current array or object being parsed. It reports false when the
+// input ends or the reader fails; the next call to Decode or Token
+// then returns io.EOF or the error.
 func (dec *Decoder) More() bool {
 	dec.eatSpaces()
 	if dec.pos >= len(dec.buf) && !dec.fill() {
@@ -84,16 +128,27 @@ func (dec *Decoder) decode(val any) error {
 		fnc = compileDecoder(elm)
 		decs.set(elm, fnc)
 	}
+	err := dec.prepare()
+	if err != nil {
+		return err
+	}
 	dec.eatSpaces()
 	if dec.pos >= len(dec.buf) && !dec.fill() {
-		return dec.errSyntax("unexpected EOF reading a byte")
+		if dec.err == io.EOF && dec.state == tokenTopValue {
+			return io.EOF
+		}
+		return dec.errEOF("unexpected EOF reading a byte")
 	}
 	head := dec.buf[dec.pos]
 	dec.pos++
-	err := addPointer(fnc(head, ref.Elem(), dec))
+	err = addPointer(fnc(head, ref.Elem(), dec))
+	if err == nil {
+		err = dec.errCut(head)
+	}
 	if err != nil {
 		return err
 	}
+	dec.valueEnd()
 	dec.eatSpaces()
 	return nil
 }
@@ -115,7 +170,7 @@ func addPointer(err error) error {
 // The offset gives the location of the end of the most recently returned token
 // and the beginning of the next token.
 func (dec *Decoder) InputOffset() int64 {
-	return int64(dec.prev + dec.pos)
+	return dec.offset(dec.pos)
 }
 
 // DisallowUnknownFields causes the Decoder to return an error when the destination
@@ -131,15 +186,101 @@ func (dec *Decoder) UseNumber() {
 	dec.opt |= optNumber
 }
 
+// UseInt64WhenExact causes the Decoder to unmarshal an integer number into an
+// interface{} as an int64, or as a uint64 if it only fits in that. Numbers
+// with a fraction or an exponent, and integers that fit in neither, are
+// unmarshaled as a float64. UseNumber takes precedence over it.
+func (dec *Decoder) UseInt64WhenExact() {
+	dec.opt |= optInt64
+}
+
+// AllowQuotedScalars causes the Decoder to accept numbers and booleans
+// quoted in JSON strings, like "42" or "true", in addition to the plain forms
+// when unmarshaling into integer, floating point and boolean values.
+// The "lenient" struct tag option does the same for a single field.
+func (dec *Decoder) AllowQuotedScalars() {
+	dec.opt |= optQuoted
+}
+
+// AllowRelaxed causes the Decoder to accept the JSONC and JSON5 extensions
+// commonly found in hand-written configuration files: // and /* */ comments,
+// trailing commas in arrays and objects, single-quoted strings, unquoted
+// object keys made of ASCII letters, digits, '_' and '$', and hexadecimal
+// integers like 0x1F for integer, floating point and interface{} values.
+// Values passed to an Unmarshaler are the input as written.
+func (dec *Decoder) AllowRelaxed() {
+	dec.opt |= optRelaxed
+}
+
+// AllowNonFinite causes the Decoder to accept NaN, Infinity and -Infinity
+// for floating point values, both as bare literals and as JSON strings.
+// Bare literals decoded into an interface{} become a float64.
+func (dec *Decoder) AllowNonFinite() {
+	dec.opt |= optNonFinite
+}
+
 // Decode reads the next JSON-encoded value from its
 // input and stores it in the value pointed to by v.
 //
 // See the documentation for Unmarshal for details about
 // the conversion of JSON into a Go value.
+//
+// At the end of the input stream, Decode returns io.EOF.
+// If the underlying reader fails, Decode returns a *ReadError
+// that wraps the error of the reader.
 func (dec *Decoder) Decode(val any) error {
 	return dec.decode(val)
 }
 
+// SkipValue reads the next JSON value from the input and discards it,
+// checking its syntax. Used between calls to Token, it skips an object
+// member's value or an array element.
+func (dec *Decoder) SkipValue() error {
+	_, err := dec.readValue(false)
+	return err
+}
+
+// ReadValue reads the next JSON value from the input and returns
+// a copy of it as written, without decoding it.
+func (dec *Decoder) ReadValue() (RawMessage, error) {
+	return dec.readValue(true)
+}
+
+func (dec *Decoder) readValue(keep bool) (RawMessage, error) {
+	err := dec.prepare()
+	if err != nil {
+		return nil, err
+	}
+	dec.eatSpaces()
+	if dec.pos >= len(dec.buf) && !dec.fill() {
+		if dec.err == io.EOF && dec.state == tokenTopValue {
+			return nil, io.EOF
+		}
+		return nil, dec.errEOF("unexpected EOF reading a byte")
+	}
+	head := dec.buf[dec.pos]
+	dec.pos++
+	if keep {
+		dec.opt |= optKeep
+	}
+	off := dec.pos - 1 // include the head.
+	err = dec.skip(head)
+	if keep {
+		dec.opt &^= optKeep
+	}
+	if err == nil {
+		err = dec.errCut(head)
+	}
+	if err != nil {
+		return nil, err
+	}
+	dec.valueEnd()
+	if keep {
+		return append(RawMessage(nil), dec.buf[off:dec.pos]...), nil
+	}
+	return nil, nil
+}
+
 // Unmarshal parses the JSON-encoded data and stores the result
 // in the value pointed to by v. If v is nil or not a pointer,
 // Unmarshal returns an InvalidUnmarshalError.
@@ -154,6 +295,14 @@ func (dec *Decoder) Decode(val any) error {
 // the value pointed at by the pointer. If the pointer is nil, Unmarshal
 // allocates a new value for it to point to.
 //
+// To unmarshal a JSON number into a big.Int, big.Float or big.Rat,
+// Unmarshal parses the digits without going through float64, so no
+// precision is lost. A big.Int accepts integers only. A big.Float with
+// zero precision gets enough precision to hold every digit. A big.Rat
+// rejects numbers with an exponent beyond ±1e6.
+// Quoted strings are parsed with the type's SetString method.
+// A Decimal keeps the coefficient and the exponent of the number as written.
+//
 // To unmarshal JSON into a value implementing the Unmarshaler interface,
 // Unmarshal calls that value's UnmarshalJSON method, including
 // when the input is a JSON null.
@@ -165,7 +314,10 @@ func (dec *Decoder) Decode(val any) error {
 // keys to the keys used by Marshal (either the struct field name or its tag),
 // preferring an exact match but also accepting a case-insensitive match. By
 // default, object keys which don't have a corresponding struct field are
-// ignored (see Decoder.DisallowUnknownFields for an alternative).
+// ignored (see Decoder.DisallowUnknownFields for an alternative), unless
+// a map field with the "inline" or "unknown" tag option collects them.
+// A field with the "lenient" tag option accepts its number or boolean
+// either plain or quoted in a JSON string.
 //
 // To unmarshal JSON into an interface value,
 // Unmarshal stores one of these in the interface value:
@@ -215,10 +367,17 @@ func (dec *Decoder) Decode(val any) error {
 // invalid UTF-16 surrogate pairs are not treated as an error.
 // Instead, they are replaced by the Unicode replacement
 // character U+FFFD.
+//
+// Unlike encoding/json, Unmarshal skips a UTF-8 byte order mark at the
+// start of data. For UTF-16 and UTF-32 data, use a Decoder with
+// DetectEncoding.
 func Unmarshal(inp []byte, val any) error {
 	dec := Decoder{
 		buf: inp,
 	}
+	if hasPrefix(inp, "\xef\xbb\xbf") {
+		dec.pos = 3 // offsets still count the byte order mark.
+	}
 	err := dec.decode(val)
 	if err == nil && dec.pos < len(dec.buf) {
 		err = dec.errSyntax("invalid character " + strconv.QuoteRune(rune(dec.buf[dec.pos])) + " after top-level value")
@@ -230,6 +389,16 @@ func compileDecoder(typ reflect.Type) decoder {
 	const lenInt = 5   // int, int8, int16, int32, int64
 	const lenUint = 6  // uint, uint8, uint16, uint32, uint64, uintptr
 	const lenFloat = 2 // float32, float64
+	switch typ {
+	case bigInt:
+		return decodeBigInt
+	case bigFloat:
+		return decodeBigFloat
+	case bigRat:
+		return decodeBigRat
+	case decimal:
+		return decodeDecimal
+	}
 	kind := typ.Kind()
 	ptr := reflect.PointerTo(typ)
 	if kind != reflect.Pointer && ptr.Implements(unmarshaler) {
@@ -301,14 +470,14 @@ func decodeTextUnmarshaler(head byte, val reflect.Value, dec *Decoder) error {
 		}
 		return err
 	}
-	if head != '"' {
+	if !dec.isQuote(head) {
 		return dec.errUnmarshalType(head, val.Type())
 	}
 	unm, ok := val.Addr().Interface().(encoding.TextUnmarshaler)
 	if !ok {
 		return nil
 	}
-	slice, err := dec.readString()
+	slice, err := dec.readString(head)
 	if err != nil {
 		return err
 	}
@@ -356,10 +525,10 @@ func decodeString(head byte, val reflect.Value, dec *Decoder) error {
 		}
 		return err
 	}
-	if head != '"' {
+	if !dec.isQuote(head) {
 		return dec.errUnmarshalType(head, val.Type())
 	}
-	str, err := dec.readString()
+	str, err := dec.readString(head)
 	if err != nil {
 		return err
 	}
@@ -376,9 +545,27 @@ func decodeInt(head byte, val reflect.Value, dec *Decoder) error {
 		}
 		return err
 	}
-	if head-'0' >= 10 && head != '-' {
+	if dec.opt&optQuoted != 0 && dec.isQuote(head) {
+		return dec.decodeQuoted(head, val, decodeInt)
+	}
+	if head-'0' >= 10 && head != '-' || dec.isNonFinite(head) {
 		return dec.errUnmarshalType(head, val.Type())
 	}
+	if dec.opt&optRelaxed != 0 && dec.isHex(head) {
+		u64, neg, err := dec.readHex(head)
+		if err == strconv.ErrRange {
+			return &UnmarshalTypeError{Value: "number", Type: val.Type(), Offset: dec.InputOffset()}
+		}
+		if err != nil {
+			return err
+		}
+		i64, ok := addSign(u64, neg)
+		if !ok || val.OverflowInt(i64) {
+			return &UnmarshalTypeError{Value: "number", Type: val.Type(), Offset: dec.InputOffset()}
+		}
+		val.SetInt(i64)
+		return nil
+	}
 	i64, err := dec.readInt(head)
 	if err != nil {
 		return err
@@ -399,9 +586,26 @@ func decodeUint(head byte, val reflect.Value, dec *Decoder) error {
 		}
 		return err
 	}
+	if dec.opt&optQuoted != 0 && dec.isQuote(head) {
+		return dec.decodeQuoted(head, val, decodeUint)
+	}
 	if head-'0' >= 10 {
 		return dec.errUnmarshalType(head, val.Type())
 	}
+	if dec.opt&optRelaxed != 0 && dec.isHex(head) {
+		u64, _, err := dec.readHex(head)
+		if err == strconv.ErrRange {
+			return &UnmarshalTypeError{Value: "number", Type: val.Type(), Offset: dec.InputOffset()}
+		}
+		if err != nil {
+			return err
+		}
+		if val.OverflowUint(u64) {
+			return &UnmarshalTypeError{Value: "number", Type: val.Type(), Offset: dec.InputOffset()}
+		}
+		val.SetUint(u64)
+		return nil
+	}
 	u64, err := dec.readUint()
 	if err != nil {
 		return err
@@ -422,9 +626,52 @@ func decodeFloat(head byte, val reflect.Value, dec *Decoder) error {
 		}
 		return err
 	}
+	if dec.isNonFinite(head) {
+		f64, err := dec.readNonFinite(head)
+		if err != nil {
+			return err
+		}
+		val.SetFloat(f64)
+		return nil
+	}
+	if dec.opt&optQuoted != 0 && dec.isQuote(head) {
+		return dec.decodeQuoted(head, val, decodeFloat)
+	}
+	if dec.opt&optNonFinite != 0 && dec.isQuote(head) {
+		off := dec.InputOffset()
+		str, err := dec.readString(head)
+		if err != nil {
+			return err
+		}
+		f64, ok := parseNonFinite(str)
+		if !ok {
+			return &UnmarshalTypeError{Value: "string", Type: val.Type(), Offset: off}
+		}
+		val.SetFloat(f64)
+		return nil
+	}
 	if head-'0' >= 10 && head != '-' {
 		return dec.errUnmarshalType(head, val.Type())
 	}
+	if dec.opt&optRelaxed != 0 && dec.isHex(head) {
+		dec.opt |= optKeep
+		off := dec.pos - 1 // include the head.
+		u64, neg, err := dec.readHex(head)
+		dec.opt &^= optKeep
+		if err == strconv.ErrRange {
+			val.SetFloat(dec.hexFloat(off))
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		f64 := float64(u64)
+		if neg {
+			f64 = -f64
+		}
+		val.SetFloat(f64)
+		return nil
+	}
 	dec.opt |= optKeep
 	off := dec.pos
 	f64, err := dec.readFloat()
@@ -449,6 +696,9 @@ func decodeFloat(head byte, val reflect.Value, dec *Decoder) error {
 }
 
 func decodeBool(head byte, val reflect.Value, dec *Decoder) error {
+	if dec.opt&optQuoted != 0 && dec.isQuote(head) {
+		return dec.decodeQuoted(head, val, decodeBool)
+	}
 	word := keywords[head]
 	if len(word) <= 0 {
 		return dec.errUnmarshalType(head, val.Type())
@@ -466,6 +716,50 @@ func decodeBool(head byte, val reflect.Value, dec *Decoder) error {
 	return nil
 }
 
+// decodeQuoted decodes a scalar quoted in a JSON string, like "42", with fnc.
+// the opening quote is already read.
+func (dec *Decoder) decodeQuoted(quo byte, val reflect.Value, fnc decoder) error {
+	off := dec.InputOffset()
+	slice, err := dec.readString(quo)
+	if err != nil {
+		return err
+	}
+	temp := dec.subDecoder(slice, off)
+	temp.opt = dec.opt &^ optQuoted
+	if len(slice) <= 0 || slice[0] == 'n' || dec.isQuote(slice[0]) {
+		return &UnmarshalTypeError{Value: "string", Type: val.Type(), Offset: off}
+	}
+	head := temp.buf[temp.pos]
+	temp.pos++
+	err = fnc(head, val, &temp)
+	if err != nil || temp.pos < len(temp.buf) {
+		return &UnmarshalTypeError{Value: "string", Type: val.Type(), Offset: off}
+	}
+	return nil
+}
+
+// subDecoder returns a Decoder that reads slice, the string just read by dec
+// whose contents start at off in the input, and reports offsets in the input
+// of dec. slice has no escapes left, so the offsets in a string with escapes
+// can't be mapped back; they all become that of the opening quote.
+func (dec *Decoder) subDecoder(slice []byte, off int64) Decoder {
+	temp := Decoder{
+		buf:  slice,
+		prev: int(off),
+	}
+	tr := &transcoder{base: off}
+	if dec.tr != nil {
+		tr.form = dec.tr.form
+		temp.tr = tr
+	}
+	if tr.units(slice) != dec.offset(dec.pos-1)-off {
+		tr.base -= tr.units([]byte{'"'}) // back to the opening quote.
+		tr.fixed = true
+		temp.tr = tr
+	}
+	return temp
+}
+
 func decodeInterface(head byte, val reflect.Value, dec *Decoder) error {
 	const ull = "ull"
 	if !val.IsNil() && val.Elem().Kind() == reflect.Pointer && val != val.Elem().Elem() {
@@ -10,6 +10,7 @@ import (
 	"errors"
 	"fmt"
 	"image"
+	"io"
 	"math"
 	"math/big"
 	"net"
@@ -17,7 +18,9 @@ import (
 	"strconv"
 	"strings"
 	"testing"
+	"testing/iotest"
 	"time"
+	"unicode/utf16"
 )
 
 type T struct {
@@ -449,21 +452,21 @@ var unmarshalTests = []unmarshalTest{
 	{in: `{"alphabet": "xyz"}`, ptr: new(U), err: fmt.Errorf("sonnet: unknown field \"alphabet\""), disallowUnknownFields: true},
 
 	// syntax errors
-	{in: `{"X": "foo", "Y"}`, err: &SyntaxError{"invalid character '}' after object key", 17}},
-	{in: `[1, 2, 3+]`, err: &SyntaxError{"invalid character '+' after array element", 9}},
-	{in: `{"X":12x}`, err: &SyntaxError{"invalid character 'x' after object key:value pair", 8}, useNumber: true},
+	{in: `{"X": "foo", "Y"}`, err: &SyntaxError{"invalid character '}' after object key", 17, ""}},
+	{in: `[1, 2, 3+]`, err: &SyntaxError{"invalid character '+' after array element", 9, ""}},
+	{in: `{"X":12x}`, err: &SyntaxError{"invalid character 'x' after object key:value pair", 8, ""}, useNumber: true},
 	{in: `[2, 3`, err: &SyntaxError{msg: "unexpected end of JSON input", Offset: 5}},
 	{in: `{"F3": -}`, ptr: new(V), out: V{F3: Number("-")}, err: &SyntaxError{msg: "invalid character '}' in numeric literal", Offset: 9}},
 
 	// raw value errors
-	{in: "\x01 42", err: &SyntaxError{"invalid character '\\x01' looking for beginning of value", 1}},
-	{in: " 42 \x01", err: &SyntaxError{"invalid character '\\x01' after top-level value", 5}},
-	{in: "\x01 true", err: &SyntaxError{"invalid character '\\x01' looking for beginning of value", 1}},
-	{in: " false \x01", err: &SyntaxError{"invalid character '\\x01' after top-level value", 8}},
-	{in: "\x01 1.2", err: &SyntaxError{"invalid character '\\x01' looking for beginning of value", 1}},
-	{in: " 3.4 \x01", err: &SyntaxError{"invalid character '\\x01' after top-level value", 6}},
-	{in: "\x01 \"string\"", err: &SyntaxError{"invalid character '\\x01' looking for beginning of value", 1}},
-	{in: " \"string\" \x01", err: &SyntaxError{"invalid character '\\x01' after top-level value", 11}},
+	{in: "\x01 42", err: &SyntaxError{"invalid character '\\x01' looking for beginning of value", 1, ""}},
+	{in: " 42 \x01", err: &SyntaxError{"invalid character '\\x01' after top-level value", 5, ""}},
+	{in: "\x01 true", err: &SyntaxError{"invalid character '\\x01' looking for beginning of value", 1, ""}},
+	{in: " false \x01", err: &SyntaxError{"invalid character '\\x01' after top-level value", 8, ""}},
+	{in: "\x01 1.2", err: &SyntaxError{"invalid character '\\x01' looking for beginning of value", 1, ""}},
+	{in: " 3.4 \x01", err: &SyntaxError{"invalid character '\\x01' after top-level value", 6, ""}},
+	{in: "\x01 \"string\"", err: &SyntaxError{"invalid character '\\x01' looking for beginning of value", 1, ""}},
+	{in: " \"string\" \x01", err: &SyntaxError{"invalid character '\\x01' after top-level value", 11, ""}},
 
 	// array tests
 	{in: `[1, 2, 3]`, ptr: new([3]int), out: [3]int{1, 2, 3}},
@@ -2354,19 +2357,19 @@ func TestUnmarshalErrorAfterMultipleJSON(t *testing.T) {
 		err error
 	}{{
 		in:  `1 false null :`,
-		err: &SyntaxError{"invalid character ':' looking for beginning of value", 14},
+		err: &SyntaxError{"invalid character ':' looking for beginning of value", 14, ""},
 	}, {
 		in:  `1 [] [,]`,
-		err: &SyntaxError{"invalid character ',' looking for beginning of value", 7},
+		err: &SyntaxError{"invalid character ',' looking for beginning of value", 7, ""},
 	}, {
 		in:  `1 [] [true:]`,
-		err: &SyntaxError{"invalid character ':' after array element", 11},
+		err: &SyntaxError{"invalid character ':' after array element", 11, ""},
 	}, {
 		in:  `1  {}    {"x"=}`,
-		err: &SyntaxError{"invalid character '=' after object key", 14},
+		err: &SyntaxError{"invalid character '=' after object key", 14, ""},
 	}, {
 		in:  `falsetruenul#`,
-		err: &SyntaxError{"invalid character '#' in literal null (expecting 'l')", 13},
+		err: &SyntaxError{"invalid character '#' in literal null (expecting 'l')", 13, ""},
 	}}
 	for i, tt := range tests {
 		dec := NewDecoder(strings.NewReader(tt.in))
@@ -2569,3 +2572,1006 @@ func TestUnmarshalMaxDepth(t *testing.T) {
 		}
 	}
 }
+
+func TestDecodeNonFinite(t *testing.T) {
+	var val struct {
+		A, B, C float64
+		D       float32
+		E       any
+		F       []any
+	}
+	data := `{"A": NaN, "B": "Infinity", "C": -Infinity, "D": "-Infinity", "E": Infinity, "F": [NaN, -Infinity], "G": NaN}`
+	dec := NewDecoder(strings.NewReader(data))
+	dec.AllowNonFinite()
+	if err := dec.Decode(&val); err != nil {
+		t.Fatalf("Decode error: %v", err)
+	}
+	if !math.IsNaN(val.A) || !math.IsInf(val.B, 1) || !math.IsInf(val.C, -1) || !math.IsInf(float64(val.D), -1) {
+		t.Errorf("Decode = %+v, want NaN, +Inf, -Inf, -Inf", val)
+	}
+	if f64, ok := val.E.(float64); !ok || !math.IsInf(f64, 1) {
+		t.Errorf("Decode E = %#v, want +Inf", val.E)
+	}
+	if len(val.F) != 2 || !math.IsNaN(val.F[0].(float64)) || !math.IsInf(val.F[1].(float64), -1) {
+		t.Errorf("Decode F = %#v, want [NaN -Inf]", val.F)
+	}
+
+	for _, data := range []string{`{"A": "Nan"}`, `{"A": -NaN}`, `{"A": Infinit}`} {
+		dec := NewDecoder(strings.NewReader(data))
+		dec.AllowNonFinite()
+		if err := dec.Decode(&val); err == nil {
+			t.Errorf("Decode(%#q) error is nil, want non-nil", data)
+		}
+	}
+	var i int
+	dec = NewDecoder(strings.NewReader(`NaN`))
+	dec.AllowNonFinite()
+	if _, ok := dec.Decode(&i).(*UnmarshalTypeError); !ok {
+		t.Errorf("Decode NaN into int: want UnmarshalTypeError")
+	}
+	if err := Unmarshal([]byte(`{"A": NaN}`), &val); err == nil {
+		t.Errorf("Unmarshal NaN without AllowNonFinite: error is nil, want non-nil")
+	}
+}
+
+func TestBigNumbers(t *testing.T) {
+	type bigs struct {
+		I *big.Int
+		F *big.Float
+		R *big.Rat
+		V big.Int
+	}
+	data := `{"I": 123456789012345678901234567890, "F": 3.14159265358979323846264338327950288, "R": 0.1e-1, "V": -98765432109876543210}`
+	var val bigs
+	if err := Unmarshal([]byte(data), &val); err != nil {
+		t.Fatalf("Unmarshal error: %v", err)
+	}
+	if got, want := val.I.String(), "123456789012345678901234567890"; got != want {
+		t.Errorf("I = %s, want %s", got, want)
+	}
+	if got, want := val.F.Text('g', -1), "3.14159265358979323846264338327950288"; got != want {
+		t.Errorf("F = %s, want %s", got, want)
+	}
+	if got, want := val.R.String(), "1/100"; got != want {
+		t.Errorf("R = %s, want %s", got, want)
+	}
+	got, err := Marshal(val)
+	if err != nil {
+		t.Fatalf("Marshal error: %v", err)
+	}
+	want := `{"I":123456789012345678901234567890,"F":3.14159265358979323846264338327950288,"R":0.01,"V":-98765432109876543210}`
+	if string(got) != want {
+		t.Errorf("Marshal = %s, want %s", got, want)
+	}
+
+	if err := Unmarshal([]byte(`{"I": "42", "R": "1/3"}`), &val); err != nil {
+		t.Fatalf("Unmarshal quoted error: %v", err)
+	}
+	if val.I.Int64() != 42 || val.R.String() != "1/3" {
+		t.Errorf("Unmarshal quoted = %v, %v, want 42, 1/3", val.I, val.R)
+	}
+	// without a decimal form, they stay the strings they were.
+	for _, tt := range []struct {
+		val  any
+		want string
+	}{
+		{val.R, `"1/3"`},
+		{new(big.Float).SetInf(false), `"+Inf"`},
+		{struct{ R big.Rat }{*big.NewRat(-2, 3)}, `{"R":"-2/3"}`},
+	} {
+		if got, err := Marshal(tt.val); err != nil || string(got) != tt.want {
+			t.Errorf("Marshal(%v) = %s, %v, want %s", tt.val, got, err, tt.want)
+		}
+	}
+	// huge exponents fail up front, rather than building a huge big.Int.
+	if _, ok := Unmarshal([]byte(`{"R": 1e999999999}`), &val).(*UnmarshalTypeError); !ok {
+		t.Errorf("Unmarshal 1e999999999 into big.Rat: want UnmarshalTypeError")
+	}
+	if dcm, err := ParseDecimal("1e999999999"); err != nil {
+		t.Errorf("ParseDecimal(1e999999999) error: %v", err)
+	} else if _, err := dcm.Rat(); !errors.Is(err, strconv.ErrRange) {
+		t.Errorf("ParseDecimal(1e999999999).Rat() error = %v, want ErrRange", err)
+	}
+	if _, ok := Unmarshal([]byte(`{"I": 1.5}`), &val).(*UnmarshalTypeError); !ok {
+		t.Errorf("Unmarshal 1.5 into big.Int: want UnmarshalTypeError")
+	}
+	if err := Unmarshal([]byte(`{"I": null}`), &val); err != nil || val.I != nil {
+		t.Errorf("Unmarshal null = %v, %v, want nil, nil", val.I, err)
+	}
+}
+
+func TestDecimal(t *testing.T) {
+	tests := []struct {
+		in, out string
+		i64     int64
+		i64Err  bool
+	}{
+		{"0", "0", 0, false},
+		{"-0", "-0", 0, false},
+		{"1.50", "1.50", 0, true},
+		{"0.001", "0.001", 0, true},
+		{"1e-7", "1e-7", 0, true},
+		{"-12.5e3", "-1.25e+4", -12500, false},
+		{"100", "100", 100, false},
+		{"123456789012345678901234567890e-10", "", 0, false},
+		{"18446744073709551615", "18446744073709551615", 0, true},
+		{"1000000000000000000000000", "1e+24", 0, true},
+		{"-9223372036854775808", "-9223372036854775808", math.MinInt64, false},
+	}
+	for _, tt := range tests {
+		dcm, err := ParseDecimal(tt.in)
+		if tt.out == "" {
+			if !errors.Is(err, strconv.ErrRange) {
+				t.Errorf("ParseDecimal(%s) error = %v, want ErrRange", tt.in, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("ParseDecimal(%s) error: %v", tt.in, err)
+		}
+		if got := dcm.String(); got != tt.out {
+			t.Errorf("ParseDecimal(%s).String() = %s, want %s", tt.in, got, tt.out)
+		}
+		i64, err := dcm.Int64()
+		if (err != nil) != tt.i64Err || err == nil && i64 != tt.i64 {
+			t.Errorf("ParseDecimal(%s).Int64() = %d, %v", tt.in, i64, err)
+		}
+		rat, err := dcm.Rat()
+		if want, _ := new(big.Rat).SetString(tt.in); err != nil || rat.Cmp(want) != 0 {
+			t.Errorf("ParseDecimal(%s).Rat() = %v, %v, want %s", tt.in, rat, err, want)
+		}
+	}
+	if _, err := ParseDecimal("01"); !errors.Is(err, strconv.ErrSyntax) {
+		t.Errorf("ParseDecimal(01) error = %v, want ErrSyntax", err)
+	}
+
+	var val struct {
+		Price  Decimal
+		Amount *Decimal
+		Quoted Decimal
+	}
+	data := `{"Price": 19.990, "Amount": -0.10, "Quoted": "3.30"}`
+	if err := Unmarshal([]byte(data), &val); err != nil {
+		t.Fatalf("Unmarshal error: %v", err)
+	}
+	if val.Price != MakeDecimal(19990, -3) || *val.Amount != MakeDecimal(-10, -2) {
+		t.Errorf("Unmarshal = %+v, want 19.990, -0.10", val)
+	}
+	if f64, _ := val.Price.Float64(); f64 != 19.99 {
+		t.Errorf("Float64() = %v, want 19.99", f64)
+	}
+	got, err := Marshal(val)
+	if err != nil {
+		t.Fatalf("Marshal error: %v", err)
+	}
+	if want := `{"Price":19.990,"Amount":-0.10,"Quoted":3.30}`; string(got) != want {
+		t.Errorf("Marshal = %s, want %s", got, want)
+	}
+	if got, err := Marshal(&val); err != nil || string(got) != `{"Price":19.990,"Amount":-0.10,"Quoted":3.30}` {
+		t.Errorf("Marshal(&val) = %s, %v", got, err)
+	}
+	if got, err := Marshal([]any{MakeDecimal(-15, -1)}); err != nil || string(got) != `[-1.5]` {
+		t.Errorf("Marshal([-1.5]) = %s, %v", got, err)
+	}
+	if _, ok := Unmarshal([]byte(`{"Price": true}`), &val).(*UnmarshalTypeError); !ok {
+		t.Errorf("Unmarshal true into Decimal: want UnmarshalTypeError")
+	}
+}
+
+func TestUseInt64WhenExact(t *testing.T) {
+	data := `[9007199254740993, -42, 18446744073709551615, 18446744073709551616, 1.5, 1e3, -9223372036854775809, -0]`
+	want := []any{int64(9007199254740993), int64(-42), uint64(18446744073709551615), 18446744073709551616.0, 1.5, 1e3, -9223372036854775809.0, int64(0)}
+	for _, rdr := range []io.Reader{strings.NewReader(data), iotest.OneByteReader(strings.NewReader(data))} {
+		dec := NewDecoder(rdr)
+		dec.UseInt64WhenExact()
+		var got []any
+		if err := dec.Decode(&got); err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("Decode = %#v, %v, want %#v", got, err, want)
+		}
+	}
+
+	dec := NewDecoder(strings.NewReader(`{"id": 12}`))
+	dec.UseInt64WhenExact()
+	dec.UseNumber()
+	var mp map[string]any
+	if err := dec.Decode(&mp); err != nil {
+		t.Fatalf("Decode error: %v", err)
+	}
+	if mp["id"] != Number("12") {
+		t.Errorf("Decode with UseNumber = %#v, want Number(12)", mp["id"])
+	}
+}
+
+func TestDecodeQuotedScalars(t *testing.T) {
+	type lenient struct {
+		I int     `json:",lenient"`
+		P *uint8  `json:",lenient"`
+		F float64 `json:",lenient"`
+		B bool    `json:",lenient"`
+		S string  `json:",lenient"`
+		N []int64
+	}
+	var val lenient
+	if err := Unmarshal([]byte(`{"I": "-42", "P": "7", "F": 1.5, "B": "true", "S": "x"}`), &val); err != nil {
+		t.Fatalf("Unmarshal error: %v", err)
+	}
+	if val.I != -42 || *val.P != 7 || val.F != 1.5 || !val.B || val.S != "x" {
+		t.Errorf("Unmarshal = %+v", val)
+	}
+	for _, data := range []string{`{"I": "4 2"}`, `{"I": "1.5"}`, `{"P": "300"}`, `{"B": "null"}`, `{"F": ""}`, `{"N": ["1"]}`} {
+		if _, ok := Unmarshal([]byte(data), &val).(*UnmarshalTypeError); !ok {
+			t.Errorf("Unmarshal(%s): want UnmarshalTypeError", data)
+		}
+	}
+
+	dec := NewDecoder(strings.NewReader(`{"N": ["1", 2, "-3"], "F": "2.5e1"}`))
+	dec.AllowQuotedScalars()
+	if err := dec.Decode(&val); err != nil {
+		t.Fatalf("Decode error: %v", err)
+	}
+	if !reflect.DeepEqual(val.N, []int64{1, 2, -3}) || val.F != 25 {
+		t.Errorf("Decode = %+v", val)
+	}
+	got, err := Marshal(lenient{I: 1, B: true})
+	if err != nil {
+		t.Fatalf("Marshal error: %v", err)
+	}
+	if want := `{"I":1,"P":null,"F":0,"B":true,"S":"","N":null}`; string(got) != want {
+		t.Errorf("Marshal = %s, want %s", got, want)
+	}
+
+	// offsets inside a string with escapes are that of its opening quote.
+	type quoted struct {
+		A int `json:",string"`
+	}
+	for _, tt := range []struct {
+		in  string
+		off int64
+	}{
+		{in: `{"A": " "}`, off: 8},
+		{in: `{"A": "\u0020"}`, off: 6},
+		{in: `{"A": "\t\u0020"}`, off: 6},
+	} {
+		err := Unmarshal([]byte(tt.in), new(quoted))
+		if syn, ok := err.(*SyntaxError); !ok || syn.Offset != tt.off {
+			t.Errorf("Unmarshal(%s) = %v, want a SyntaxError at %d", tt.in, err, tt.off)
+		}
+	}
+	for _, in := range []string{`{"I": "1x"}`, `{"I": "\u0031x"}`} {
+		err := Unmarshal([]byte(in), &val)
+		if ute, ok := err.(*UnmarshalTypeError); !ok || ute.Offset != 7 {
+			t.Errorf("Unmarshal(%s) = %v, want an UnmarshalTypeError at 7", in, err)
+		}
+	}
+}
+
+func TestDecodeRelaxed(t *testing.T) {
+	type config struct {
+		Name  string
+		Port  int
+		Mask  uint32
+		Tags  []string
+		Pair  [2]float64
+		Extra map[string]any
+	}
+	data := `// leading comment
+{
+	/* block
+	   comment */ Name: 'it\'s "quoted"',
+	port: 0x1F90, // trailing comment
+	'Mask': 0XFFff,
+	Tags: ['a', "b",],
+	Pair: [-0x10, 1.5,],
+	Skipped: {nested: [1, 2,], $id: 'x',},
+	Extra: {a_1: [0x10,], b: {c: 'd',},},
+} // done`
+	var got config
+	dec := NewDecoder(strings.NewReader(data))
+	dec.AllowRelaxed()
+	if err := dec.Decode(&got); err != nil {
+		t.Fatalf("Decode error: %v", err)
+	}
+	want := config{
+		Name:  `it's "quoted"`,
+		Port:  8080,
+		Mask:  0xffff,
+		Tags:  []string{"a", "b"},
+		Pair:  [2]float64{-16, 1.5},
+		Extra: map[string]any{"a_1": []any{16.0}, "b": map[string]any{"c": "d"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Decode =\n%#v\nwant\n%#v", got, want)
+	}
+	if dec.More() {
+		t.Errorf("More after the trailing comment = true, want false")
+	}
+
+	for _, data := range []string{`[1,,]`, `{,}`, `{a b: 1}`, `0x`, `/ 1`, `{1a: 1}`} {
+		dec := NewDecoder(strings.NewReader(data))
+		dec.AllowRelaxed()
+		var val any
+		if err := dec.Decode(&val); err == nil {
+			t.Errorf("Decode(%#q) error is nil, want non-nil", data)
+		}
+	}
+	// an unterminated comment fails where the input ends, even after a value.
+	for _, data := range []string{`[1, /* open`, `[1] /* oops`} {
+		dec := NewDecoder(strings.NewReader(data))
+		dec.AllowRelaxed()
+		var val any
+		err := dec.Decode(&val)
+		if err == nil {
+			err = dec.Decode(&val)
+		}
+		if syn, ok := err.(*SyntaxError); !ok || syn.msg != "comment not terminated" || syn.Offset != int64(len(data)) {
+			t.Errorf("Decode(%#q) error = %v, want comment not terminated at %d", data, err, len(data))
+		}
+	}
+	// hexadecimal numbers too large for a uint64.
+	for _, ptr := range []any{new(int64), new(uint8)} {
+		dec := NewDecoder(strings.NewReader(`0x1FFFFFFFFFFFFFFFFF`))
+		dec.AllowRelaxed()
+		if err, ok := dec.Decode(ptr).(*UnmarshalTypeError); !ok || err.Offset != 20 {
+			t.Errorf("Decode 0x1FFFFFFFFFFFFFFFFF into %T: error = %v, want an UnmarshalTypeError at 20", ptr, err)
+		}
+	}
+	for _, ptr := range []any{new(float64), new(any)} {
+		dec := NewDecoder(strings.NewReader(`0x1FFFFFFFFFFFFFFFFF`))
+		dec.AllowRelaxed()
+		if err := dec.Decode(ptr); err != nil || reflect.ValueOf(ptr).Elem().Interface() != any(0x1FFFFFFFFFFFFFFFFF*1.0) {
+			t.Errorf("Decode 0x1FFFFFFFFFFFFFFFFF into %T = %v, %v", ptr, reflect.ValueOf(ptr).Elem(), err)
+		}
+	}
+	for _, data := range []string{`[1,]`, `{a: 1}`, `'x'`, `0x10`, `// c` + "\n1"} {
+		var val any
+		if err := Unmarshal([]byte(data), &val); err == nil {
+			t.Errorf("strict Unmarshal(%#q) error is nil, want non-nil", data)
+		}
+	}
+}
+
+func TestInlineFields(t *testing.T) {
+	type Meta struct {
+		ID   int    `json:"id"`
+		Kind string `json:"kind,omitempty"`
+	}
+	type Audit struct {
+		By string `json:"by"`
+	}
+	type doc struct {
+		Name  string                `json:"name"`
+		Meta  Meta                  `json:",inline"`
+		Audit *Audit                `json:"audit,inline"`
+		Rest  map[string]RawMessage `json:",unknown"`
+	}
+	var got doc
+	src := `{"name":"a","id":1,"kind":"k","by":"me","x":[1, 2],"y":{"z":null}}`
+	if err := Unmarshal([]byte(src), &got); err != nil {
+		t.Fatalf("Unmarshal error: %v", err)
+	}
+	want := doc{
+		Name:  "a",
+		Meta:  Meta{ID: 1, Kind: "k"},
+		Audit: &Audit{By: "me"},
+		Rest:  map[string]RawMessage{"x": RawMessage(`[1, 2]`), "y": RawMessage(`{"z":null}`)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unmarshal =\n%+v\nwant\n%+v", got, want)
+	}
+
+	got.Rest["name"] = RawMessage(`"hidden"`)
+	dst, err := Marshal(got)
+	if err != nil {
+		t.Fatalf("Marshal error: %v", err)
+	}
+	if want := `{"name":"a","id":1,"kind":"k","by":"me","x":[1,2],"y":{"z":null}}`; string(dst) != want {
+		t.Errorf("Marshal = %s, want %s", dst, want)
+	}
+
+	type loose struct {
+		A    int            `json:"a"`
+		More map[string]any `json:",inline"`
+	}
+	var lse loose
+	dec := NewDecoder(strings.NewReader(`{"a":1,"b":true,"c":"d"}`))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&lse); err != nil {
+		t.Fatalf("Decode error: %v", err)
+	}
+	if want := (loose{A: 1, More: map[string]any{"b": true, "c": "d"}}); !reflect.DeepEqual(lse, want) {
+		t.Errorf("Decode = %+v, want %+v", lse, want)
+	}
+	dst, err = Marshal(loose{A: 2})
+	if err != nil {
+		t.Fatalf("Marshal error: %v", err)
+	}
+	if want := `{"a":2}`; string(dst) != want {
+		t.Errorf("Marshal = %s, want %s", dst, want)
+	}
+
+	// the members come out as those of a map field would.
+	extra := map[string]any{"<b>": "x&y", "d": map[string]any{"z": 1, "y": []any{"<"}}, "c": 1.5}
+	for _, html := range []bool{true, false} {
+		var fld, unk bytes.Buffer
+		enc := NewEncoder(&fld)
+		enc.SetEscapeHTML(html)
+		enc.Encode(extra)
+		enc = NewEncoder(&unk)
+		enc.SetEscapeHTML(html)
+		enc.Encode(loose{A: 2, More: extra})
+		if want := `{"a":2,` + fld.String()[1:]; unk.String() != want {
+			t.Errorf("Encode with html %t = %s, want %s", html, unk.String(), want)
+		}
+	}
+}
+
+// dataEOFReader returns its data together with io.EOF.
+type dataEOFReader struct {
+	src []byte
+}
+
+func (rdr *dataEOFReader) Read(buf []byte) (int, error) {
+	read := copy(buf, rdr.src)
+	rdr.src = rdr.src[read:]
+	if len(rdr.src) == 0 {
+		return read, io.EOF
+	}
+	return read, nil
+}
+
+func TestDecodeReaderErrors(t *testing.T) {
+	errBroken := errors.New("broken pipe")
+	dec := NewDecoder(io.MultiReader(strings.NewReader(`[1, 2] {"a": "b`), iotest.ErrReader(errBroken)))
+	var arr []int
+	if err := dec.Decode(&arr); err != nil {
+		t.Fatalf("Decode error: %v", err)
+	}
+	var mp map[string]string
+	err := dec.Decode(&mp)
+	var rde *ReadError
+	if !errors.As(err, &rde) || !errors.Is(err, errBroken) || rde.Offset != 15 {
+		t.Fatalf("Decode error = %#v, want a ReadError wrapping %v at offset 15", err, errBroken)
+	}
+	if err := dec.Decode(&mp); !errors.Is(err, errBroken) {
+		t.Errorf("Decode after the failure = %v, want %v", err, errBroken)
+	}
+	if dec.More() {
+		t.Errorf("More after the failure = true, want false")
+	}
+
+	dec = NewDecoder(iotest.TimeoutReader(strings.NewReader(strings.Repeat(" ", 5000) + `"x"`)))
+	var str string
+	if err := dec.Decode(&str); !errors.Is(err, iotest.ErrTimeout) {
+		t.Errorf("Decode error = %v, want %v", err, iotest.ErrTimeout)
+	}
+
+	for _, rdr := range []io.Reader{
+		&dataEOFReader{src: []byte(`{"a": 1} 2 "three"`)},
+		iotest.DataErrReader(strings.NewReader(`{"a": 1} 2 "three"`)),
+		iotest.OneByteReader(strings.NewReader(`{"a": 1} 2 "three"`)),
+	} {
+		dec := NewDecoder(rdr)
+		var got []any
+		for {
+			var val any
+			err := dec.Decode(&val)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("Decode error: %v", err)
+			}
+			got = append(got, val)
+		}
+		if want := []any{map[string]any{"a": 1.0}, 2.0, "three"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Decode = %v, want %v", got, want)
+		}
+		if tok, err := dec.Token(); tok != nil || err != io.EOF {
+			t.Errorf("Token at the end = %v, %v, want nil, io.EOF", tok, err)
+		}
+	}
+
+	dec = NewDecoder(strings.NewReader(`[1, 2`))
+	if err := dec.Decode(&arr); err == io.EOF || !strings.Contains(fmt.Sprint(err), "unexpected EOF") {
+		t.Errorf("Decode of a truncated value = %v, want a syntax error", err)
+	}
+
+	// failures inside an escape and right after a number, which may go on.
+	for _, src := range []string{`"a\u00`, `"a\u`, `12`, `-1.5e`} {
+		dec := NewDecoder(io.MultiReader(strings.NewReader(src), iotest.ErrReader(errBroken)))
+		var val any
+		if err := dec.Decode(&val); !errors.As(err, &rde) || !errors.Is(err, errBroken) {
+			t.Errorf("Decode(%q) = %v, %v, want a ReadError", src, val, err)
+		}
+		if err := dec.Decode(&val); !errors.Is(err, errBroken) {
+			t.Errorf("Decode(%q) after the failure = %v, want %v", src, err, errBroken)
+		}
+		dec = NewDecoder(io.MultiReader(strings.NewReader(src), iotest.ErrReader(errBroken)))
+		if _, err := dec.ReadToken(); !errors.Is(err, errBroken) {
+			t.Errorf("ReadToken(%q) = %v, want %v", src, err, errBroken)
+		}
+	}
+}
+
+func TestDecoderReset(t *testing.T) {
+	dec := NewDecoderSize(strings.NewReader(`{"a": 1, "b": 2} [`), 1<<12)
+	dec.DisallowUnknownFields()
+	type small struct {
+		A int `json:"a"`
+	}
+	var val small
+	if err := dec.Decode(&val); err == nil {
+		t.Fatalf("Decode error is nil, want an unknown field error")
+	}
+	for idx, src := range []string{`{"a": 1}`, ` {"a": 2} `, `{"a": 3}`} {
+		if idx == 2 {
+			dec.Release()
+		}
+		dec.Reset(strings.NewReader(src))
+		if dec.InputOffset() != 0 {
+			t.Errorf("InputOffset after Reset = %d, want 0", dec.InputOffset())
+		}
+		var val small
+		if err := dec.Decode(&val); err != nil || val.A != idx+1 {
+			t.Errorf("Decode after Reset = %v, %v, want {%d}, nil", val, err, idx+1)
+		}
+		if err := dec.Decode(&val); err != io.EOF {
+			t.Errorf("Decode at the end = %v, want io.EOF", err)
+		}
+	}
+	dec.Reset(strings.NewReader(`{"a": 1, "c": 2}`))
+	if err := dec.Decode(&val); err == nil {
+		t.Errorf("Decode error after Reset is nil, want an unknown field error")
+	}
+	dec.Release()
+
+	var zero Decoder
+	zero.Reset(strings.NewReader(`true`))
+	var flg bool
+	if err := zero.Decode(&flg); err != nil || !flg {
+		t.Errorf("Decode with a zero Decoder after Reset = %v, %v, want true, nil", flg, err)
+	}
+}
+
+func TestReadToken(t *testing.T) {
+	src := `{"a": [1, -2.5e1, "sé", true, null, 18446744073709551615], "b": {}}`
+	dec := NewDecoder(strings.NewReader(src))
+	type tok struct {
+		kind Kind
+		raw  string
+	}
+	var got []tok
+	for {
+		if kind := dec.PeekKind(); kind == KindInvalid {
+			break
+		}
+		rtk, err := dec.ReadToken()
+		if err != nil {
+			t.Fatalf("ReadToken error: %v", err)
+		}
+		got = append(got, tok{rtk.Kind, string(rtk.Raw)})
+	}
+	want := []tok{
+		{KindObject, "{"}, {KindString, "a"}, {KindArray, "["},
+		{KindNumber, "1"}, {KindNumber, "-2.5e1"}, {KindString, "sé"}, {KindBool, "true"}, {KindNull, "null"},
+		{KindNumber, "18446744073709551615"}, {KindArrayEnd, "]"},
+		{KindString, "b"}, {KindObject, "{"}, {KindObjectEnd, "}"}, {KindObjectEnd, "}"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadToken =\n%v\nwant\n%v", got, want)
+	}
+	if _, err := dec.ReadToken(); err != io.EOF {
+		t.Errorf("ReadToken at the end error = %v, want io.EOF", err)
+	}
+
+	num := RawToken{Kind: KindNumber, Raw: []byte("-2.5e1")}
+	if f64, err := num.Float(); f64 != -25 || err != nil {
+		t.Errorf("Float = %v, %v, want -25, nil", f64, err)
+	}
+	if _, err := num.Int(); err == nil {
+		t.Errorf("Int of -2.5e1 error is nil, want non-nil")
+	}
+	big := RawToken{Kind: KindNumber, Raw: []byte("18446744073709551615")}
+	if u64, err := big.Uint(); u64 != math.MaxUint64 || err != nil {
+		t.Errorf("Uint = %v, %v, want MaxUint64, nil", u64, err)
+	}
+	if _, err := big.Int(); !errors.Is(err, strconv.ErrRange) {
+		t.Errorf("Int of MaxUint64 error = %v, want ErrRange", err)
+	}
+	if flg, err := (RawToken{Kind: KindBool, Raw: []byte("false")}).Bool(); flg || err != nil {
+		t.Errorf("Bool = %v, %v, want false, nil", flg, err)
+	}
+	var ute *UnmarshalTypeError
+	if _, err := (RawToken{Kind: KindString, Raw: []byte("1")}).Int(); !errors.As(err, &ute) || ute.Value != "string" {
+		t.Errorf("Int of a string error = %v, want an UnmarshalTypeError", err)
+	}
+
+	dec = NewDecoder(strings.NewReader(`[0x1F, 'x', NaN]`))
+	dec.AllowRelaxed()
+	dec.AllowNonFinite()
+	dec.ReadToken()
+	if rtk, _ := dec.ReadToken(); rtk.Kind != KindNumber {
+		t.Errorf("ReadToken of a hexadecimal number kind = %v, want number", rtk.Kind)
+	} else if i64, err := rtk.Int(); i64 != 31 || err != nil {
+		t.Errorf("Int of 0x1F = %v, %v, want 31, nil", i64, err)
+	}
+	if rtk, _ := dec.ReadToken(); rtk.Kind != KindString || rtk.String() != "x" {
+		t.Errorf("ReadToken of a single-quoted string = %v %q, want string \"x\"", rtk.Kind, rtk.Raw)
+	}
+	if rtk, _ := dec.ReadToken(); rtk.Kind != KindNumber {
+		t.Errorf("ReadToken of NaN kind = %v, want number", rtk.Kind)
+	} else if f64, err := rtk.Float(); !math.IsNaN(f64) || err != nil {
+		t.Errorf("Float of NaN = %v, %v, want NaN, nil", f64, err)
+	}
+
+	src = "[" + strings.Repeat(`12, "abc", true, `, 100) + "null]"
+	dec = NewDecoderSize(strings.NewReader(src), 2*len(src))
+	dec.ReadToken()
+	allocs := testing.AllocsPerRun(50, func() {
+		for idx := 0; idx < 3; idx++ {
+			rtk, err := dec.ReadToken()
+			if err != nil {
+				t.Fatalf("ReadToken error: %v", err)
+			}
+			switch rtk.Kind {
+			case KindNumber:
+				rtk.Int()
+			case KindBool:
+				rtk.Bool()
+			}
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("ReadToken allocates %v times per run, want 0", allocs)
+	}
+}
+
+func TestTokenGrammar(t *testing.T) {
+	for _, src := range []string{`[1 2]`, `{"a" 1}`, `[,1]`, `{"a"::1}`, `[1}`, `{"a":1]`, `{1:2}`, `[1,]`, `{"a":1,}`, `]`, `{"a",1}`} {
+		dec := NewDecoder(strings.NewReader(src))
+		var err error
+		for err == nil {
+			_, err = dec.Token()
+		}
+		var syn *SyntaxError
+		if !errors.As(err, &syn) {
+			t.Errorf("Token(%#q) error = %v, want a SyntaxError", src, err)
+		}
+	}
+
+	dec := NewDecoder(strings.NewReader(`{"a": {"b": 1}, "c": [1, [2], 3]} 4`))
+	var got []any
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Token error: %v", err)
+		}
+		got = append(got, tok)
+		if tok == "a" || tok == 1.0 {
+			var val any
+			if err := dec.Decode(&val); err != nil {
+				t.Fatalf("Decode error: %v", err)
+			}
+			got = append(got, val)
+		}
+	}
+	want := []any{Delim('{'), "a", map[string]any{"b": 1.0}, "c", Delim('['), 1.0, []any{2.0}, 3.0, Delim(']'), Delim('}'), 4.0}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Token and Decode =\n%v\nwant\n%v", got, want)
+	}
+
+	dec = NewDecoder(strings.NewReader(`{"a": 1}`))
+	dec.Token()
+	var val any
+	if err := dec.Decode(&val); err == nil {
+		t.Errorf("Decode of an object key error is nil, want non-nil")
+	}
+	dec = NewDecoder(strings.NewReader(`[1`))
+	dec.Token()
+	dec.Token()
+	if _, err := dec.Token(); err == io.EOF || err == nil {
+		t.Errorf("Token in an unterminated array error = %v, want a syntax error", err)
+	}
+
+	dec = NewDecoder(strings.NewReader(`{a: [1, 2,], 'b': 3,}`))
+	dec.AllowRelaxed()
+	got = got[:0]
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("relaxed Token error: %v", err)
+		}
+		got = append(got, tok)
+	}
+	want = []any{Delim('{'), "a", Delim('['), 1.0, 2.0, Delim(']'), "b", 3.0, Delim('}')}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("relaxed Token =\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestSkipAndReadValue(t *testing.T) {
+	big := `{"x": [` + strings.Repeat(`"padding", `, 500) + `1]}`
+	src := `{"skip": ` + big + `, "keep": ` + big + `, "n": 1} [true]`
+	dec := NewDecoder(iotest.OneByteReader(strings.NewReader(src)))
+	if tok, err := dec.Token(); tok != Delim('{') || err != nil {
+		t.Fatalf("Token = %v, %v, want {", tok, err)
+	}
+	var raw RawMessage
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			t.Fatalf("Token error: %v", err)
+		}
+		switch tok {
+		case "skip":
+			err = dec.SkipValue()
+		case "keep":
+			raw, err = dec.ReadValue()
+		default:
+			var num int
+			err = dec.Decode(&num)
+		}
+		if err != nil {
+			t.Fatalf("%v: error: %v", tok, err)
+		}
+	}
+	if string(raw) != big {
+		t.Errorf("ReadValue = %.40s..., want %.40s...", raw, big)
+	}
+	if tok, err := dec.Token(); tok != Delim('}') || err != nil {
+		t.Fatalf("Token = %v, %v, want }", tok, err)
+	}
+	if raw, err := dec.ReadValue(); string(raw) != "[true]" || err != nil {
+		t.Errorf("ReadValue = %s, %v, want [true], nil", raw, err)
+	}
+	if err := dec.SkipValue(); err != io.EOF {
+		t.Errorf("SkipValue at the end = %v, want io.EOF", err)
+	}
+
+	dec = NewDecoder(strings.NewReader(`[1 2]`))
+	dec.Token()
+	dec.SkipValue()
+	if err := dec.SkipValue(); err == nil {
+		t.Errorf("SkipValue without a comma error is nil, want non-nil")
+	}
+	dec = NewDecoder(strings.NewReader(`{"a": tru}`))
+	if _, err := dec.ReadValue(); err == nil {
+		t.Errorf("ReadValue of invalid JSON error is nil, want non-nil")
+	}
+}
+
+func TestStackPointer(t *testing.T) {
+	src := `{"a/b": [1, {"~k": null}], "c": []} 2`
+	dec := NewDecoder(strings.NewReader(src))
+	want := []string{"", "/a~1b", "/a~1b", "/a~1b/0", "/a~1b/1", "/a~1b/1/~0k", "/a~1b/1/~0k", "/a~1b/1", "/a~1b", "/c", "/c", "/c", "", ""}
+	for idx, ptr := range want {
+		if idx == 6 {
+			// compiled decoders leave the stack as it was.
+			var val any
+			if err := dec.Decode(&val); err != nil || val != nil {
+				t.Fatalf("Decode = %v, %v, want nil", val, err)
+			}
+		} else if _, err := dec.Token(); err != nil {
+			t.Fatalf("Token %d: %v", idx, err)
+		}
+		if got := dec.StackPointer(); got != ptr {
+			t.Errorf("StackPointer after %d = %q, want %q", idx, got, ptr)
+		}
+	}
+	dec = NewDecoder(strings.NewReader(`[{"x": [0, 1`))
+	for idx := 0; idx < 6; idx++ {
+		if _, err := dec.Token(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if dec.StackDepth() != 3 {
+		t.Fatalf("StackDepth = %d, want 3", dec.StackDepth())
+	}
+	kinds := []Kind{KindArray, KindObject, KindArray}
+	for idx, cnt := range []int{1, 1, 2} {
+		if kind, got := dec.StackIndex(idx); kind != kinds[idx] || got != cnt {
+			t.Errorf("StackIndex(%d) = %v, %d, want %v, %d", idx, kind, got, kinds[idx], cnt)
+		}
+	}
+	if kind, cnt := dec.StackIndex(3); kind != KindInvalid || cnt != 0 {
+		t.Errorf("StackIndex(3) = %v, %d", kind, cnt)
+	}
+	if got := dec.StackPointer(); got != "/0/x/1" {
+		t.Errorf("StackPointer = %q, want /0/x/1", got)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		in   string
+		opts ValidateOptions
+		msg  string
+		off  int64
+		path string
+	}{
+		{in: ` {"a": [1, {"b": null}], "c": "d"} `},
+		{in: `{"a": [1, {"b": nul}]}`, msg: "invalid character '}' in literal null (expecting 'l')", off: 20, path: "/a/1/b"},
+		{in: `{"a": [1, 2 3]}`, msg: "invalid character '3' after array element", off: 13, path: "/a/1"},
+		{in: `{"a/~": [}`, msg: "invalid character '}' looking for beginning of value", off: 10, path: "/a~1~0"},
+		{in: `[1] 2`, msg: "invalid character '2' after top-level value", off: 4},
+		{in: ` `, msg: "unexpected EOF reading a byte", off: 1},
+		{in: `{"a": [`, msg: "unexpected EOF reading a byte", off: 7, path: "/a"},
+		{in: "[\"ok\", \"\xff\"]", opts: ValidateOptions{StrictUTF8: true}, msg: "invalid UTF-8 in string", off: 8, path: "/1"},
+		{in: "[\"ok\", \"\xff\"]"},
+		{in: `{"a": 1, "b": {"a": 2}, "a": 3}`, opts: ValidateOptions{RejectDuplicateKeys: true}, msg: `duplicate object key "a"`, off: 27, path: "/a"},
+		{in: `[[[1]], [[2]]]`, opts: ValidateOptions{MaxDepth: 3}},
+		{in: `[[[1]], [[[2]]]]`, opts: ValidateOptions{MaxDepth: 3}, msg: "exceeded max depth 3", off: 11, path: "/1/0/0"},
+	}
+	for _, tt := range tests {
+		err := ValidateWithOptions([]byte(tt.in), tt.opts)
+		if tt.msg == "" {
+			if err != nil {
+				t.Errorf("ValidateWithOptions(%q) = %v, want nil", tt.in, err)
+			}
+			continue
+		}
+		syn, ok := err.(*SyntaxError)
+		if !ok {
+			t.Errorf("ValidateWithOptions(%q) = %v, want a SyntaxError", tt.in, err)
+			continue
+		}
+		if syn.msg != tt.msg || syn.Offset != tt.off || syn.Path != tt.path {
+			t.Errorf("ValidateWithOptions(%q) = %q, %d, %q, want %q, %d, %q", tt.in, syn.msg, syn.Offset, syn.Path, tt.msg, tt.off, tt.path)
+		}
+	}
+	if err := Validate([]byte(`{"a": [tru]}`)); err == nil || err.Error() != "sonnet: invalid character ']' in literal true (expecting 'e') at /a/0" {
+		t.Errorf("Validate = %v", err)
+	}
+}
+
+func TestDetectEncoding(t *testing.T) {
+	const src = `{"a": ["é", "😀", 1]}`
+	utf16be := func(str string, bom bool) []byte {
+		var dst []byte
+		if bom {
+			dst = append(dst, 0xfe, 0xff)
+		}
+		for _, unit := range utf16.Encode([]rune(str)) {
+			dst = append(dst, byte(unit>>8), byte(unit))
+		}
+		return dst
+	}
+	swap := func(src []byte, size int) []byte {
+		dst := make([]byte, len(src))
+		for idx := 0; idx+size <= len(src); idx += size {
+			for off := 0; off < size; off++ {
+				dst[idx+off] = src[idx+size-1-off]
+			}
+		}
+		return dst
+	}
+	utf32be := func(str string) []byte {
+		var dst []byte
+		for _, run := range str {
+			dst = append(dst, byte(run>>24), byte(run>>16), byte(run>>8), byte(run))
+		}
+		return dst
+	}
+	inputs := map[string][]byte{
+		"UTF-8":         []byte(src),
+		"UTF-8 BOM":     append([]byte("\xef\xbb\xbf"), src...),
+		"UTF-16BE":      utf16be(src, false),
+		"UTF-16BE BOM":  utf16be(src, true),
+		"UTF-16LE":      swap(utf16be(src, false), 2),
+		"UTF-16LE BOM":  swap(utf16be(src, true), 2),
+		"UTF-32BE":      utf32be(src),
+		"UTF-32LE BOM":  swap(utf32be("\ufeff"+src), 4),
+		"UTF-16LE tiny": swap(utf16be("1", false), 2),
+	}
+	for name, inp := range inputs {
+		dec := NewDecoder(iotest.OneByteReader(bytes.NewReader(inp)))
+		dec.DetectEncoding()
+		var got any
+		if err := dec.Decode(&got); err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		want := any(map[string]any{"a": []any{"é", "😀", 1.0}})
+		if name == "UTF-16LE tiny" {
+			want = 1.0
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", name, got, want)
+		}
+		if dec.InputOffset() != int64(len(inp)) {
+			t.Errorf("%s: InputOffset = %d, want %d", name, dec.InputOffset(), len(inp))
+		}
+	}
+
+	// offsets count the bytes of the input.
+	inp := swap(utf16be(`["😀", x]`, true), 2)
+	dec := NewDecoder(bytes.NewReader(inp))
+	dec.DetectEncoding()
+	var val any
+	err := dec.Decode(&val)
+	if syn, ok := err.(*SyntaxError); !ok || syn.Offset != 2+2*8 {
+		t.Errorf("Decode = %v, want a SyntaxError at 18", err)
+	}
+	// so do those found by the decoders of map keys and ,string fields.
+	offset := func(err error) int64 {
+		switch err := err.(type) {
+		case *SyntaxError:
+			return err.Offset
+		case *UnmarshalTypeError:
+			return err.Offset
+		}
+		return -1
+	}
+	type quoted struct {
+		A int `json:",string"`
+	}
+	for _, tt := range []struct {
+		in  string
+		val any
+	}{
+		{in: `{"😀": {"€": 0}}`, val: new(map[string]map[int8]int)},
+		{in: `{"😀": 0, "A": ""}`, val: new(quoted)},
+		{in: `{"😀": 0, "A": " "}`, val: new(quoted)},
+		{in: `{"😀": 0, "A": "\u0020"}`, val: new(quoted)},
+	} {
+		off := offset(Unmarshal([]byte(tt.in), tt.val))
+		if off < 0 {
+			t.Errorf("Unmarshal(%q): no error with an offset", tt.in)
+			continue
+		}
+		dec := NewDecoder(bytes.NewReader(utf16be(tt.in, true)))
+		dec.DetectEncoding()
+		want := 2 + 2*int64(len(utf16.Encode([]rune(tt.in[:off]))))
+		if got := offset(dec.Decode(tt.val)); got != want {
+			t.Errorf("Decode(%q) in UTF-16: offset %d, want %d", tt.in, got, want)
+		}
+	}
+	// Unmarshal skips a UTF-8 byte order mark on its own.
+	if err := Unmarshal(inputs["UTF-8 BOM"], &val); err != nil || !reflect.DeepEqual(val, map[string]any{"a": []any{"é", "😀", 1.0}}) {
+		t.Errorf("Unmarshal with a BOM = %v, %v", val, err)
+	}
+	if syn, ok := Unmarshal([]byte("\xef\xbb\xbf[x]"), &val).(*SyntaxError); !ok || syn.Offset != 5 {
+		t.Errorf("Unmarshal with a BOM: error offset is not 5")
+	}
+	// without it, the old behavior stays.
+	dec = NewDecoder(bytes.NewReader(inputs["UTF-8 BOM"]))
+	if err := dec.Decode(&val); err == nil {
+		t.Errorf("Decode with a BOM: no error")
+	}
+}
+
+func TestDecoderBufferBounded(t *testing.T) {
+	// many small values and the tokens of one large array, both far larger
+	// than the buffer, shouldn't make it grow.
+	vals := strings.Repeat(`{"a": [1, "xyz"]} `, 1<<16)
+	dec := NewDecoder(strings.NewReader(vals))
+	for {
+		var val any
+		if err := dec.Decode(&val); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cap(dec.buf) > 16<<10 {
+		t.Errorf("after %d bytes of values, cap(buf) = %d", len(vals), cap(dec.buf))
+	}
+	arr := "[" + strings.Repeat(`12345, "abc", `, 1<<16) + "0]"
+	dec = NewDecoder(strings.NewReader(arr))
+	for {
+		if _, err := dec.Token(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cap(dec.buf) > 16<<10 {
+		t.Errorf("after an array of %d bytes, cap(buf) = %d", len(arr), cap(dec.buf))
+	}
+}
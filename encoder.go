@@ -15,11 +15,71 @@ type (
 		out    io.Writer
 		html   bool
 		level  uint
-		prefix string
-		indent string
+		layout IndentOptions
 		seen   map[any]struct{}
-	}
-	encoder func([]byte, reflect.Value, *Encoder) ([]byte, error)
+		opts   EncodeOptions
+		flt    floatFormat
+		// the grammar state of WriteToken, the states of the enclosing
+		// values, and whether a top-level value has been written.
+		state byte
+		stack []byte
+		more  bool
+		tok   []byte
+	}
+	// EncodeOptions holds encoder settings that the standard library doesn't offer.
+	// The zero value gives the output of Marshal.
+	EncodeOptions struct {
+		// UnorderedMaps writes map keys in map iteration order, which is faster for
+		// large maps, rather than sorted. Equal maps may then produce different output.
+		UnorderedMaps bool
+		// Canonical makes the output follow RFC 8785, the JSON Canonicalization Scheme.
+		// See Canonicalize for the rules. It takes precedence over UnorderedMaps.
+		Canonical bool
+		// NonFinite selects how NaN and ±Inf floating point values are encoded.
+		NonFinite NonFiniteMode
+		// FloatFormat formats floating point values with one of the fmt verbs
+		// %f (never an exponent), %e (always an exponent) or %g (shortest),
+		// optionally with a precision such as "%.2f". The empty string keeps the
+		// default formatting. The "format" struct tag option overrides it per field.
+		FloatFormat string
+	}
+	// IndentOptions holds the layout of indented output. The zero value, with
+	// a Prefix and an Indent, gives the layout Indent uses. IndentWithOptions,
+	// MarshalIndentWithOptions and Encoder.SetIndentOptions take it where
+	// Indent, MarshalIndent and Encoder.SetIndent, whose signatures follow
+	// encoding/json, only have room for the prefix and the indent; those
+	// are the same as passing DefaultIndentOptions(prefix, indent).
+	IndentOptions struct {
+		// Prefix begins each line after the first.
+		Prefix string
+		// Indent is repeated once per level of nesting.
+		Indent string
+		// InlineWidth writes an array or object on one line when its one-line form,
+		// with a space after each comma, takes at most InlineWidth bytes.
+		// Zero turns it off.
+		InlineWidth int
+		// InlineScalarArrays writes arrays that contain no arrays or objects
+		// on one line, however long they are.
+		InlineScalarArrays bool
+		// NoSpaceAfterColon leaves out the space that Indent writes between
+		// an object key and its value.
+		NoSpaceAfterColon bool
+		// Colors highlights the tokens for terminals. The zero value writes no colors.
+		Colors Colors
+	}
+	// Colors holds the ANSI escape sequences written before each kind of token,
+	// such as "\x1b[32m" for green. A colored token is followed by the reset
+	// sequence "\x1b[0m". Kinds with an empty sequence are left as they are.
+	Colors struct {
+		Key    string
+		String string
+		Number string
+		Bool   string
+		Null   string
+	}
+	// NonFiniteMode is a way to encode NaN and ±Inf, which JSON can't represent.
+	NonFiniteMode byte
+	encoder       func([]byte, reflect.Value, *Encoder) ([]byte, error)
 )
 
 var (
@@ -31,6 +91,18 @@ const (
 	maxCycles = 1000
 )
 
+const (
+	// NonFiniteError makes encoding fail with an UnsupportedValueError. This is the default.
+	NonFiniteError NonFiniteMode = iota
+	// NonFiniteNull encodes the values as null.
+	NonFiniteNull
+	// NonFiniteString encodes the values as the strings "NaN", "Infinity" and "-Infinity".
+	NonFiniteString
+	// NonFiniteLiteral encodes the values as the bare literals NaN, Infinity and -Infinity,
+	// the way JSON5 does. The output is not valid JSON.
+	NonFiniteLiteral
+)
+
 // Marshal returns the JSON encoding of v.
 //
 // Marshal traverses the value v recursively.
@@ -48,7 +120,14 @@ const (
 // Boolean values encode as JSON booleans.
 //
 // Floating point, integer, and Number values encode as JSON numbers.
-// NaN and +/-Inf values will return an [UnsupportedValueError].
+// NaN and +/-Inf values will return an [UnsupportedValueError],
+// unless EncodeOptions.NonFinite selects another encoding.
+// big.Int, big.Float and big.Rat values encode as JSON numbers with
+// every digit kept. Those that have no such form, a big.Rat like 1/3 or an
+// infinite big.Float, encode as the JSON string of their MarshalText method,
+// which is how pointers to them encoded before, unless EncodeOptions.NonFinite
+// selects another encoding for the infinities. Decimal values encode as
+// their String form.
 //
 // String values encode as JSON strings coerced to valid UTF-8,
 // replacing invalid bytes with the Unicode replacement rune.
@@ -110,6 +189,14 @@ const (
 //
 //	Int64String int64 `json:",string"`
 //
+// The "format" option sets how a floating point field is formatted,
+// in the same way EncodeOptions.FloatFormat does:
+//
+//	Price float64 `json:"price,format:%.2f"`
+//
+// An invalid format or one on a field that isn't a floating point number
+// makes Marshal return an error.
+//
 // The key name will be used if it's a non-empty string consisting of
 // only Unicode letters, digits, and ASCII punctuation except quotation
 // marks, backslash, and comma.
@@ -122,6 +209,18 @@ const (
 // An anonymous struct field of interface type is treated the same as having
 // that type as its name, rather than being anonymous.
 //
+// The "inline" option flattens a named struct field, or a pointer to one,
+// as if it were anonymous:
+//
+//	Meta Meta `json:",inline"`
+//
+// On a field of map type with string keys, either "inline" or "unknown"
+// collects the object members that match no other field when unmarshaling,
+// and marshals the entries of the map after the other fields. Entries whose
+// key is the name of another field are left out.
+//
+//	Rest map[string]RawMessage `json:",unknown"`
+//
 // The Go visibility rules for struct fields are amended for JSON when
 // deciding which field to marshal or unmarshal. If there are
 // multiple fields at the same level, and that level is the least
@@ -142,7 +241,8 @@ const (
 //
 // Map values encode as JSON objects. The map's key type must either be a
 // string, an integer type, or implement encoding.TextMarshaler. The map keys
-// are sorted and used as JSON object keys by applying the following rules,
+// are sorted (unless EncodeOptions.UnorderedMaps is set)
+// and used as JSON object keys by applying the following rules,
 // subject to the UTF-8 coercion described for string values above:
 //   - keys of any string type are used directly
 //   - encoding.TextMarshalers are marshaled
@@ -162,27 +262,64 @@ const (
 // handle them. Passing cyclic structures to Marshal will result in
 // an error.
 func Marshal(val any) ([]byte, error) {
+	enc := Encoder{html: true, opts: DefaultEncodeOptions()}
+	return enc.encode(val)
+}
+
+// MarshalOptions is like Marshal but encodes with the given options.
+func MarshalOptions(val any, opts EncodeOptions) ([]byte, error) {
 	enc := Encoder{html: true}
+	err := enc.SetOptions(opts)
+	if err != nil {
+		return nil, err
+	}
 	return enc.encode(val)
 }
 
+// DefaultEncodeOptions returns the options Marshal and NewEncoder use.
+func DefaultEncodeOptions() EncodeOptions {
+	return EncodeOptions{}
+}
+
 // MarshalIndent is like Marshal but applies Indent to format the output.
 // Each JSON element in the output will begin on a new line beginning with prefix
 // followed by one or more copies of indent according to the indentation nesting.
 func MarshalIndent(val any, prefix, indent string) ([]byte, error) {
+	return MarshalIndentWithOptions(val, DefaultIndentOptions(prefix, indent))
+}
+
+// MarshalIndentWithOptions is like MarshalIndent but lays out the output
+// as IndentWithOptions does.
+func MarshalIndentWithOptions(val any, opts IndentOptions) ([]byte, error) {
 	dst, err := Marshal(val)
 	if err == nil {
 		var buf bytes.Buffer
 		buf.Grow(len(dst) * 2)
-		err = Indent(&buf, dst, prefix, indent)
+		err = IndentWithOptions(&buf, dst, opts)
 		dst = buf.Bytes()
 	}
 	return dst, err
 }
 
+// DefaultIndentOptions returns the options Indent uses for prefix and indent.
+func DefaultIndentOptions(prefix, indent string) IndentOptions {
+	return IndentOptions{Prefix: prefix, Indent: indent}
+}
+
+// DefaultColors returns the colors jq uses: blue keys, green strings and gray null.
+func DefaultColors() Colors {
+	return Colors{
+		Key:    "\x1b[34;1m",
+		String: "\x1b[32m",
+		Number: "\x1b[39m",
+		Bool:   "\x1b[39m",
+		Null:   "\x1b[1;30m",
+	}
+}
+
 // NewEncoder returns a new encoder that writes to w.
 func NewEncoder(out io.Writer) *Encoder {
-	return &Encoder{out: out}
+	return &Encoder{out: out, opts: DefaultEncodeOptions()}
 }
 
 // Encode writes the JSON encoding of v to the stream,
@@ -195,14 +332,23 @@ func (enc *Encoder) Encode(val any) error {
 	if err != nil {
 		return err
 	}
-	if enc.prefix != "" || enc.indent != "" {
-		var buf bytes.Buffer
-		buf.Grow(len(dst) * 2)
-		err = Indent(&buf, dst, enc.prefix, enc.indent)
+	if enc.layout.Prefix != "" || enc.layout.Indent != "" || enc.layout.Colors != (Colors{}) {
+		comp := compactor{
+			dst:       make([]byte, 0, len(dst)*2),
+			src:       dst,
+			prefix:    enc.layout.Prefix,
+			indent:    enc.layout.Indent,
+			colon:     !enc.layout.NoSpaceAfterColon,
+			scalars:   enc.layout.InlineScalarArrays,
+			width:     enc.layout.InlineWidth,
+			colors:    enc.layout.colors(),
+			nonFinite: enc.opts.NonFinite == NonFiniteLiteral,
+		}
+		err = comp.compactAll()
 		if err != nil {
 			return err
 		}
-		dst = buf.Bytes()
+		dst = comp.dst
 	}
 	wrt, err := enc.out.Write(dst)
 	if err != nil {
@@ -230,8 +376,236 @@ func (enc *Encoder) SetEscapeHTML(html bool) {
 // value as if indented by the package-level function Indent(dst, src, prefix, indent).
 // Calling SetIndent("", "") disables indentation.
 func (enc *Encoder) SetIndent(prefix, indent string) {
-	enc.prefix = prefix
-	enc.indent = indent
+	enc.SetIndentOptions(DefaultIndentOptions(prefix, indent))
+}
+
+// SetIndentOptions is like SetIndent but lays out each value
+// as IndentWithOptions does. An empty Prefix and Indent disable indentation,
+// while Colors still apply.
+func (enc *Encoder) SetIndentOptions(opts IndentOptions) {
+	enc.layout = opts
+}
+
+// SetOptions replaces the encoder's options with opts.
+// It doesn't affect the settings of SetEscapeHTML and SetIndent.
+// An invalid FloatFormat is an error, and leaves the options as they were.
+func (enc *Encoder) SetOptions(opts EncodeOptions) error {
+	var flt floatFormat
+	if opts.FloatFormat != "" {
+		var ok bool
+		flt, ok = parseFloatFormat(opts.FloatFormat)
+		if !ok {
+			return errors.New("sonnet: invalid float format: " + strconv.Quote(opts.FloatFormat))
+		}
+	}
+	enc.opts, enc.flt = opts, flt
+	return nil
+}
+
+// WriteToken writes the next token of the output stream, the counterpart of
+// Decoder.Token. The token is one of the types Token holds, or a Go integer
+// or float32 for a number, and Delim opens and closes arrays and objects. Commas and colons are written as needed,
+// and a string where an object key is expected is written as the key.
+//
+// WriteToken returns an error for a token that would make the output invalid,
+// such as a delimiter that doesn't match or a number where a key is expected.
+// The settings of SetEscapeHTML, SetIndent and SetIndentOptions apply, except
+// InlineWidth and InlineScalarArrays, which need values written at once by
+// WriteValue. Consecutive top-level values are separated by a newline.
+func (enc *Encoder) WriteToken(tok Token) error {
+	dst := enc.tok[:0]
+	key := enc.state == tokenObjectStart || enc.state == tokenObjectComma
+	switch tok := tok.(type) {
+	case Delim:
+		switch {
+		case tok == ']' && (enc.state == tokenArrayStart || enc.state == tokenArrayComma),
+			tok == '}' && (enc.state == tokenObjectStart || enc.state == tokenObjectComma):
+			if enc.state == tokenArrayComma || enc.state == tokenObjectComma {
+				dst = enc.newline(dst, len(enc.stack)-1)
+			}
+			dst = append(dst, byte(tok))
+			enc.state = enc.stack[len(enc.stack)-1]
+			enc.stack = enc.stack[:len(enc.stack)-1]
+			enc.valueEnd()
+		case (tok == '[' || tok == '{') && !key:
+			if len(enc.stack) >= maxDep {
+				return errors.New("sonnet: exceeded max depth")
+			}
+			dst = enc.separate(dst)
+			dst = append(dst, byte(tok))
+			enc.stack = append(enc.stack, enc.state)
+			enc.state = tokenArrayStart
+			if tok == '{' {
+				enc.state = tokenObjectStart
+			}
+		default:
+			return enc.errToken(strconv.QuoteRune(rune(tok)))
+		}
+	case string:
+		dst = enc.separate(dst)
+		color := enc.color('"', key)
+		dst = append(dst, color...)
+		dst = appendString(dst, tok, enc.html)
+		dst = enc.tokenEnd(dst, color, key)
+	case nil, bool, float64, float32, Number,
+		int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, uintptr:
+		if key {
+			return enc.errToken("a non-string token")
+		}
+		var head byte = '0'
+		if tok == nil {
+			head = 'n'
+		} else if _, ok := tok.(bool); ok {
+			head = 't'
+		}
+		dst = enc.separate(dst)
+		color := enc.color(head, false)
+		dst = append(dst, color...)
+		var err error
+		dst, err = appendAny(dst, tok, enc)
+		if err != nil {
+			return err
+		}
+		dst = enc.tokenEnd(dst, color, false)
+	default:
+		return &UnsupportedTypeError{Type: reflect.TypeOf(tok)}
+	}
+	return enc.writeToken(dst)
+}
+
+// WriteValue writes a complete JSON value to the output stream, placing it
+// as WriteToken would. raw is checked, compacted, and laid out with the
+// settings of SetEscapeHTML and SetIndentOptions. A string is written as the
+// key where an object key is expected.
+func (enc *Encoder) WriteValue(raw RawMessage) error {
+	key := enc.state == tokenObjectStart || enc.state == tokenObjectComma
+	var color string
+	if key {
+		color = enc.color('"', true)
+	}
+	dst := enc.separate(enc.tok[:0])
+	dst = append(dst, color...)
+	start := len(dst)
+	comp := compactor{
+		dst:       dst,
+		src:       raw,
+		html:      enc.html,
+		dep:       len(enc.stack),
+		nonFinite: enc.opts.NonFinite == NonFiniteLiteral,
+	}
+	if !key {
+		comp.prefix, comp.indent = enc.layout.Prefix, enc.layout.Indent
+		comp.colon = !enc.layout.NoSpaceAfterColon
+		comp.scalars, comp.width = enc.layout.InlineScalarArrays, enc.layout.InlineWidth
+		comp.colors = enc.layout.colors()
+	}
+	err := comp.compactAll()
+	if err != nil {
+		return err
+	}
+	if rest := bytes.TrimLeft(raw[comp.read:], " \t\r\n"); len(rest) > 0 {
+		comp.read = len(raw) - len(rest)
+		return comp.errSyntax("invalid character " + strconv.QuoteRune(rune(rest[0])) + " after top-level value")
+	}
+	if key && comp.dst[start] != '"' {
+		return enc.errToken("a non-string value")
+	}
+	dst = enc.tokenEnd(comp.dst, color, key)
+	return enc.writeToken(dst)
+}
+
+// separate appends what goes before the next token in the current state.
+func (enc *Encoder) separate(dst []byte) []byte {
+	switch enc.state {
+	case tokenTopValue:
+		if enc.more {
+			dst = append(dst, '\n')
+		}
+	case tokenArrayComma, tokenObjectComma:
+		dst = append(dst, ',')
+		fallthrough
+	case tokenArrayStart, tokenObjectStart:
+		dst = enc.newline(dst, len(enc.stack))
+	}
+	return dst
+}
+
+// newline appends a newline and the indentation of the level dep, if any.
+func (enc *Encoder) newline(dst []byte, dep int) []byte {
+	if enc.layout.Prefix == "" && enc.layout.Indent == "" {
+		return dst
+	}
+	dst = append(dst, '\n')
+	dst = append(dst, enc.layout.Prefix...)
+	for idx := 0; idx < dep; idx++ {
+		dst = append(dst, enc.layout.Indent...)
+	}
+	return dst
+}
+
+// color returns the color of the scalar starting with head, or of an object key.
+func (enc *Encoder) color(head byte, key bool) string {
+	clr := enc.layout.colors()
+	if clr == nil {
+		return ""
+	}
+	if key {
+		return clr.Key
+	}
+	return clr.pick(head)
+}
+
+// tokenEnd appends what goes right after a scalar or an object key,
+// and moves the grammar state past it.
+func (enc *Encoder) tokenEnd(dst []byte, color string, key bool) []byte {
+	if color != "" {
+		dst = append(dst, colorReset...)
+	}
+	if !key {
+		enc.valueEnd()
+		return dst
+	}
+	dst = append(dst, ':')
+	if !enc.layout.NoSpaceAfterColon && (enc.layout.Prefix != "" || enc.layout.Indent != "") {
+		dst = append(dst, ' ')
+	}
+	enc.state = tokenObjectValue
+	return dst
+}
+
+// valueEnd moves the grammar state past a complete value.
+func (enc *Encoder) valueEnd() {
+	switch enc.state {
+	case tokenTopValue:
+		enc.more = true
+	case tokenArrayStart, tokenArrayComma:
+		enc.state = tokenArrayComma
+	case tokenObjectValue:
+		enc.state = tokenObjectComma
+	}
+}
+
+func (enc *Encoder) writeToken(dst []byte) error {
+	enc.tok = dst[:0]
+	wrt, err := enc.out.Write(dst)
+	if err != nil {
+		return err
+	}
+	if wrt != len(dst) {
+		return io.ErrShortWrite
+	}
+	return nil
+}
+
+func (enc *Encoder) errToken(got string) error {
+	exp := "a value"
+	switch enc.state {
+	case tokenArrayStart, tokenArrayComma:
+		exp = "a value or ']'"
+	case tokenObjectStart, tokenObjectComma:
+		exp = "an object key or '}'"
+	}
+	return errors.New("sonnet: cannot write " + got + " where " + exp + " is expected")
 }
 
 // Compact appends to dst the JSON-encoded src with
@@ -244,18 +618,31 @@ func Compact(dst *bytes.Buffer, src []byte) error {
 		src:  src,
 		html: false,
 	}
-	comp.eatSpaces()
-	if len(src) <= comp.read {
-		return comp.errSyntax("unexpected EOF reading a byte")
-	}
-	head := src[comp.read]
-	comp.read++
-	err := comp.compact(head)
+	err := comp.compactAll()
 	if err != nil {
 		return err
 	}
-	if comp.write < comp.read {
-		comp.dst = append(comp.dst, comp.src[comp.write:comp.read]...)
+	dst.Write(comp.dst)
+	return nil
+}
+
+// Standardize appends to dst the strict RFC 8259 form of src, which may use
+// the extensions accepted by Decoder.AllowRelaxed. Comments and trailing
+// commas are removed, single-quoted strings and unquoted object keys become
+// double-quoted strings, and hexadecimal numbers are written in decimal.
+// Whitespace is kept, so the layout of the document stays the same.
+func Standardize(dst *bytes.Buffer, src []byte) error {
+	dst.Grow(len(src))
+	buf := dst.AvailableBuffer()
+	comp := compactor{
+		dst:        buf,
+		src:        src,
+		relaxed:    true,
+		keepSpaces: true,
+	}
+	err := comp.compactAll()
+	if err != nil {
+		return err
 	}
 	dst.Write(comp.dst)
 	return nil
@@ -302,32 +689,54 @@ func HTMLEscape(dst *bytes.Buffer, src []byte) {
 // For example, if src has no trailing spaces, neither will dst;
 // if src ends in a trailing newline, so will dst.
 func Indent(dst *bytes.Buffer, src []byte, prefix, indent string) error {
+	return IndentWithOptions(dst, src, DefaultIndentOptions(prefix, indent))
+}
+
+// IndentWithOptions is like Indent but lays out src as opts says.
+// With InlineWidth or InlineScalarArrays, an array or object written on one line
+// has a space after each comma, like [1, 2, 3], and everything inside it is
+// written on that line too.
+func IndentWithOptions(dst *bytes.Buffer, src []byte, opts IndentOptions) error {
 	dst.Grow(len(src) * 2)
 	buf := dst.AvailableBuffer()
 	comp := compactor{
-		dst:    buf,
-		src:    src,
-		html:   false,
-		prefix: prefix,
-		indent: indent,
-	}
-	comp.eatSpaces()
-	if len(src) <= comp.read {
-		return comp.errSyntax("unexpected EOF reading a byte")
-	}
-	head := src[comp.read]
-	comp.read++
-	err := comp.compact(head)
+		dst:     buf,
+		src:     src,
+		prefix:  opts.Prefix,
+		indent:  opts.Indent,
+		colon:   !opts.NoSpaceAfterColon,
+		scalars: opts.InlineScalarArrays,
+		width:   opts.InlineWidth,
+		colors:  opts.colors(),
+	}
+	err := comp.compactAll()
 	if err != nil {
 		return err
 	}
-	if comp.write < comp.read {
-		comp.dst = append(comp.dst, comp.src[comp.write:comp.read]...)
-	}
 	dst.Write(comp.dst)
 	return nil
 }
 
+func (opts *IndentOptions) colors() *Colors {
+	if opts.Colors == (Colors{}) {
+		return nil
+	}
+	return &opts.Colors
+}
+
+// pick returns the color of the scalar starting with head.
+func (clr *Colors) pick(head byte) string {
+	switch head {
+	case '"', '\'':
+		return clr.String
+	case 't', 'f':
+		return clr.Bool
+	case 'n':
+		return clr.Null
+	}
+	return clr.Number
+}
+
 func (enc *Encoder) encode(val any) ([]byte, error) {
 	ref := reflect.ValueOf(val)
 	typ := reflect.TypeOf(val)
@@ -350,14 +759,33 @@ func (enc *Encoder) encode(val any) ([]byte, error) {
 	}
 	num += len(dst)
 	lens.set(typ, (num+num&1)>>1)
-	return dst, nil
+	if enc.opts.Canonical {
+		src := dst
+		dst, err = canonicalize(mem.Get(len(src))[:0], src)
+		mem.Put(src)
+	}
+	return dst, err
 }
 
 func compileEncoder(typ reflect.Type, addr bool) encoder {
 	const lenInt = 5   // int, int8, int16, int32, int64
 	const lenUint = 6  // uint, uint8, uint16, uint32, uint64, uintptr
 	const lenFloat = 2 // float32, float64
+	switch typ {
+	case bigInt:
+		return encodeBigInt
+	case bigFloat:
+		return encodeBigFloat
+	case bigRat:
+		return encodeBigRat
+	case decimal:
+		return encodeDecimal
+	}
 	kind := typ.Kind()
+	if kind == reflect.Pointer && isBig(typ.Elem()) {
+		// the pointer types implement Marshaler, skip them.
+		return compilePointerEncoder(typ)
+	}
 	ptr := reflect.PointerTo(typ)
 	if addr && kind != reflect.Pointer && ptr.Implements(marshaler) {
 		return compilePointerMarshalerEncoder(typ)
@@ -503,7 +931,7 @@ func encodeUint(dst []byte, val reflect.Value, enc *Encoder) ([]byte, error) {
 }
 
 func encodeFloat(dst []byte, val reflect.Value, enc *Encoder) ([]byte, error) {
-	return appendFloat(dst, val.Float(), val.Type().Bits())
+	return appendFloat(dst, val.Float(), val.Type().Bits(), enc)
 }
 
 func encodeBool(dst []byte, val reflect.Value, enc *Encoder) ([]byte, error) {
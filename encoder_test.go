@@ -8,13 +8,16 @@ import (
 	"bytes"
 	"encoding"
 	"fmt"
+	"io"
 	"log"
 	"math"
 	"reflect"
 	"regexp"
 	"runtime/debug"
 	"strconv"
+	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 type Optionals struct {
@@ -1188,3 +1191,547 @@ func TestMarshalerError(t *testing.T) {
 		}
 	}
 }
+
+func TestMarshalNondeterministic(t *testing.T) {
+	opts := DefaultEncodeOptions()
+	opts.UnorderedMaps = true
+	tests := []any{
+		map[string]int{"a": 1, "b": 2, "c": 3},
+		map[int]string{-1: "x", 0: "y", 10: "z"},
+		map[string]any{"a": []any{1.0, "b"}, "c": map[string]any{"d": nil}},
+		struct{ M map[string]bool }{M: map[string]bool{"<": true, "&": false}},
+	}
+	for i, tt := range tests {
+		got, err := MarshalOptions(tt, opts)
+		if err != nil {
+			t.Fatalf("#%d: MarshalOptions error: %v", i, err)
+		}
+		var gotVal, wantVal any
+		if err := Unmarshal(got, &gotVal); err != nil {
+			t.Fatalf("#%d: Unmarshal(%s) error: %v", i, got, err)
+		}
+		want, _ := Marshal(tt)
+		if err := Unmarshal(want, &wantVal); err != nil {
+			t.Fatalf("#%d: Unmarshal(%s) error: %v", i, want, err)
+		}
+		if !reflect.DeepEqual(gotVal, wantVal) {
+			t.Errorf("#%d: MarshalOptions = %s, want equivalent of %s", i, got, want)
+		}
+	}
+
+	// options that don't mention the order keep the keys sorted.
+	val := map[string]any{"c": 1, "b": map[string]int{"z": 1, "y": 2}, "a": math.NaN()}
+	for i := 0; i < 10; i++ {
+		got, err := MarshalOptions(val, EncodeOptions{NonFinite: NonFiniteNull})
+		if want := `{"a":null,"b":{"y":2,"z":1},"c":1}`; err != nil || string(got) != want {
+			t.Fatalf("MarshalOptions = %s, %v, want %s", got, err, want)
+		}
+	}
+}
+
+func TestCanonicalize(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{`0`, `0`},
+		{`-0`, `0`},
+		{`1e21`, `1e+21`},
+		{`1e20`, `100000000000000000000`},
+		{`1e-7`, `1e-7`},
+		{`0.000001`, `0.000001`},
+		{`333333333.33333329`, `333333333.3333333`},
+		{`1.7976931348623157e308`, `1.7976931348623157e+308`},
+		{`5e-324`, `5e-324`},
+		{`-5e-324`, `-5e-324`},
+		{`9007199254740992`, `9007199254740992`},
+		{`295147905179352830000`, `295147905179352830000`},
+		{`4.50`, `4.5`},
+		{`2e-3`, `0.002`},
+		{`0.000000001`, `1e-9`},
+		{`123e-20`, `1.23e-18`},
+		{`"\u20ac\u00e9<>&\u2028\/\u001f\t"`, "\"€é<>&\u2028/\\u001f\\t\""},
+		{
+			`{"\u20ac":"Euro Sign","\r":"Carriage Return","\ufb33":"Hebrew Letter Dalet With Dagesh","1":"One","\ud83d\ude00":"Emoji: Grinning Face","\u0080":"Control","\u00f6":"Latin Small Letter O With Diaeresis"}`,
+			"{\"\\r\":\"Carriage Return\",\"1\":\"One\",\"\u0080\":\"Control\",\"\u00f6\":\"Latin Small Letter O With Diaeresis\",\"\u20ac\":\"Euro Sign\",\"\U0001f600\":\"Emoji: Grinning Face\",\"\ufb33\":\"Hebrew Letter Dalet With Dagesh\"}",
+		},
+		{` { "b" : [ true , null , 1.0 ] , "a" : { } } `, `{"a":{},"b":[true,null,1]}`},
+	}
+	for _, tt := range tests {
+		got, err := Canonicalize([]byte(tt.in))
+		if err != nil {
+			t.Errorf("Canonicalize(%#q) error: %v", tt.in, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("Canonicalize(%#q) = %#q, want %#q", tt.in, got, tt.want)
+		}
+	}
+	for _, in := range []string{`{"a":1,"a":2}`, `1e400`, `[1,]`, `{} {}`} {
+		if _, err := Canonicalize([]byte(in)); err == nil {
+			t.Errorf("Canonicalize(%#q) error is nil, want non-nil", in)
+		}
+	}
+
+	opts := DefaultEncodeOptions()
+	opts.Canonical = true
+	val := struct {
+		Z float64
+		A map[string]any
+		S string
+	}{Z: 1e21, A: map[string]any{"y": 1, "x": "<"}, S: "\u2028"}
+	got, err := MarshalOptions(val, opts)
+	if err != nil {
+		t.Fatalf("MarshalOptions error: %v", err)
+	}
+	want := "{\"A\":{\"x\":\"<\",\"y\":1},\"S\":\"\u2028\",\"Z\":1e+21}"
+	if string(got) != want {
+		t.Errorf("MarshalOptions = %#q, want %#q", got, want)
+	}
+}
+
+func TestMarshalNonFinite(t *testing.T) {
+	val := struct {
+		N float64
+		P float32
+		M float64 `json:",string"`
+		A any
+	}{math.NaN(), float32(math.Inf(1)), math.Inf(-1), math.NaN()}
+	tests := []struct {
+		mode NonFiniteMode
+		want string
+	}{
+		{NonFiniteNull, `{"N":null,"P":null,"M":null,"A":null}`},
+		{NonFiniteString, `{"N":"NaN","P":"Infinity","M":"-Infinity","A":"NaN"}`},
+		{NonFiniteLiteral, `{"N":NaN,"P":Infinity,"M":"-Infinity","A":NaN}`},
+	}
+	for _, tt := range tests {
+		opts := DefaultEncodeOptions()
+		opts.NonFinite = tt.mode
+		got, err := MarshalOptions(val, opts)
+		if err != nil {
+			t.Errorf("NonFinite=%d: MarshalOptions error: %v", tt.mode, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("NonFinite=%d: MarshalOptions = %s, want %s", tt.mode, got, tt.want)
+		}
+	}
+	nan := struct {
+		F float32  `json:",string"`
+		P *float64 `json:",string"`
+	}{float32(math.NaN()), new(float64)}
+	*nan.P = math.NaN()
+	got, err := MarshalOptions(nan, EncodeOptions{NonFinite: NonFiniteNull})
+	if want := `{"F":null,"P":null}`; err != nil || string(got) != want {
+		t.Errorf("MarshalOptions = %s, %v, want %s", got, err, want)
+	}
+	if _, err := Marshal(val); err == nil {
+		t.Errorf("Marshal error is nil, want UnsupportedValueError")
+	}
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.SetIndent("", " ")
+	enc.SetOptions(EncodeOptions{NonFinite: NonFiniteLiteral})
+	if err := enc.Encode([]float64{math.Inf(-1), 1}); err != nil {
+		t.Fatalf("Encode error: %v", err)
+	}
+	if got, want := buf.String(), "[\n -Infinity,\n 1\n]"; got != want {
+		t.Errorf("Encode = %q, want %q", got, want)
+	}
+}
+
+func TestMarshalFloatFormat(t *testing.T) {
+	type price struct {
+		Price float64  `json:"price,format:%.2f"`
+		Ratio *float32 `json:"ratio,format:%e"`
+		Plain float64  `json:"plain"`
+	}
+	ratio := float32(0.25)
+	val := price{Price: 3, Ratio: &ratio, Plain: 1e6}
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"", `{"price":3.00,"ratio":2.5e-01,"plain":1000000}`},
+		{"%g", `{"price":3.00,"ratio":2.5e-01,"plain":1e+06}`},
+		{"%.1e", `{"price":3.00,"ratio":2.5e-01,"plain":1.0e+06}`},
+	}
+	for _, tt := range tests {
+		got, err := MarshalOptions(val, EncodeOptions{FloatFormat: tt.format})
+		if err != nil {
+			t.Fatalf("MarshalOptions(%q) error: %v", tt.format, err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("MarshalOptions(%q) = %s, want %s", tt.format, got, tt.want)
+		}
+		var back price
+		if err := Unmarshal(got, &back); err != nil {
+			t.Fatalf("Unmarshal(%s) error: %v", got, err)
+		}
+		if back.Price != val.Price || *back.Ratio != ratio || back.Plain != val.Plain {
+			t.Errorf("Unmarshal(%s) = %+v, want %+v", got, back, val)
+		}
+	}
+
+	if _, err := MarshalOptions(1.5, EncodeOptions{FloatFormat: "%d"}); err == nil {
+		t.Errorf("MarshalOptions with %%d error is nil")
+	}
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	if err := enc.SetOptions(EncodeOptions{FloatFormat: "%d"}); err == nil {
+		t.Errorf("SetOptions with %%d error is nil")
+	}
+	if err := enc.SetOptions(EncodeOptions{FloatFormat: "%e"}); err != nil {
+		t.Fatal(err)
+	}
+	enc.Encode(1.5)
+	// an empty format goes back to the default.
+	enc.SetOptions(DefaultEncodeOptions())
+	enc.Encode(1.5)
+	if want := "1.5e+00" + "1.5"; buf.String() != want {
+		t.Errorf("Encode = %s, want %s", buf.String(), want)
+	}
+	type badVerb struct {
+		F float64 `json:",format:%x"`
+	}
+	if _, err := Marshal(badVerb{}); err == nil {
+		t.Errorf("Marshal(badVerb) error is nil")
+	}
+	type badKind struct {
+		I int `json:",format:%.2f"`
+	}
+	if _, err := Marshal(badKind{}); err == nil {
+		t.Errorf("Marshal(badKind) error is nil")
+	}
+}
+
+func TestStandardize(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"{\n\t// comment\n\tname: 'it\\'s \"x\"', /* inline */\n\t'list': [0x1F, -0X10, 1,],\n}\n",
+			"{\n\t\n\t\"name\": \"it's \\\"x\\\"\", \n\t\"list\": [31, -16, 1]\n}\n"},
+		{`[ 0xffffffffffffffffff, "\'", ]`, `[ 4722366482869645213695, "'" ]`},
+		{"/* head */ true // tail", " true "},
+		{`{"a": [],}`, `{"a": []}`},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := Standardize(&buf, []byte(tt.in)); err != nil {
+			t.Errorf("Standardize(%q) error: %v", tt.in, err)
+			continue
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("Standardize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+	for _, in := range []string{`[1,,]`, `{1a: 2}`, `'\q'`, `[0x]`, `1 2`, `{a: 1`, `[1] /* oops`, `[1, /* open`} {
+		var buf bytes.Buffer
+		if err := Standardize(&buf, []byte(in)); err == nil {
+			t.Errorf("Standardize(%q) error is nil, want non-nil", in)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	src := `// config
+{ // the server
+  "name": 'x', port: 80,
+
+
+  /* tags */ "tags": ["a","b",],
+  "nested": {"z": null, // z
+  "a": [ ]},
+} // end
+`
+	want := `// config
+{
+    // the server
+    "name": 'x',
+    port: 80,
+
+    /* tags */
+    "tags": ["a", "b"],
+    "nested": {
+        "z": null, // z
+        "a": []
+    }
+} // end
+`
+	got, err := Format([]byte(src), FormatOptions{Indent: "    ", Relaxed: true, ArrayWidth: 20})
+	if err != nil {
+		t.Fatalf("Format error: %v", err)
+	}
+	if string(got) != want {
+		t.Errorf("Format =\n%s\nwant\n%s", got, want)
+	}
+
+	got, err = Format([]byte(`{"b": [1, 2], "a": {"d": 1, "c": 2}}`), FormatOptions{SortKeys: true})
+	if err != nil {
+		t.Fatalf("Format error: %v", err)
+	}
+	if want := "{\n\t\"a\": {\n\t\t\"c\": 2,\n\t\t\"d\": 1\n\t},\n\t\"b\": [\n\t\t1,\n\t\t2\n\t]\n}\n"; string(got) != want {
+		t.Errorf("Format sorted = %q, want %q", got, want)
+	}
+	got, err = Format([]byte("{\"x\": 0,\n\n\"a\" /* c */ : 1}"), FormatOptions{Relaxed: true})
+	if err != nil {
+		t.Fatalf("Format error: %v", err)
+	}
+	if want := "{\n\t\"x\": 0,\n\n\t/* c */\n\t\"a\": 1\n}\n"; string(got) != want {
+		t.Errorf("Format with a comment before the colon = %q, want %q", got, want)
+	}
+	for _, src := range []string{`{"a": 1,}`, `// c` + "\n1", `[1 2]`, `{"a" 1}`, `[1] 2`} {
+		if _, err := Format([]byte(src), FormatOptions{}); err == nil {
+			t.Errorf("Format(%#q) error is nil, want non-nil", src)
+		}
+	}
+	// an unterminated comment is an error, rather than text that goes missing.
+	for _, src := range []string{`[1, /* open`, `[1, 2] /* TODO: fix this`} {
+		_, err := Format([]byte(src), FormatOptions{Relaxed: true})
+		if syn, ok := err.(*SyntaxError); !ok || syn.msg != "comment not terminated" || syn.Offset != int64(len(src)) {
+			t.Errorf("Format(%#q) error = %v, want comment not terminated at %d", src, err, len(src))
+		}
+	}
+}
+
+func TestIndentWithOptions(t *testing.T) {
+	src := `{"point":[1,2,3],"tags":{"a":"x","b":"y"},"rows":[[1,2],[3,4]],"long":[10000,20000,30000,40000]}`
+	tests := []struct {
+		opts IndentOptions
+		want string
+	}{{
+		opts: IndentOptions{Indent: "  ", InlineWidth: 20},
+		want: `{
+  "point": [1, 2, 3],
+  "tags": {"a": "x", "b": "y"},
+  "rows": [[1, 2], [3, 4]],
+  "long": [
+    10000,
+    20000,
+    30000,
+    40000
+  ]
+}`,
+	}, {
+		opts: IndentOptions{Prefix: ">", Indent: "\t", InlineScalarArrays: true, NoSpaceAfterColon: true},
+		want: "{\n>\t\"point\":[1, 2, 3],\n>\t\"tags\":{\n>\t\t\"a\":\"x\",\n>\t\t\"b\":\"y\"\n>\t},\n>\t\"rows\":[\n>\t\t[1, 2],\n>\t\t[3, 4]\n>\t],\n>\t\"long\":[10000, 20000, 30000, 40000]\n>}",
+	}, {
+		opts: DefaultIndentOptions("", "\t"),
+		want: "{\n\t\"point\": [\n\t\t1,\n\t\t2,\n\t\t3\n\t],\n\t\"tags\": {\n\t\t\"a\": \"x\",\n\t\t\"b\": \"y\"\n\t},\n\t\"rows\": [\n\t\t[\n\t\t\t1,\n\t\t\t2\n\t\t],\n\t\t[\n\t\t\t3,\n\t\t\t4\n\t\t]\n\t],\n\t\"long\": [\n\t\t10000,\n\t\t20000,\n\t\t30000,\n\t\t40000\n\t]\n}",
+	}}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := IndentWithOptions(&buf, []byte(src), tt.opts); err != nil {
+			t.Fatalf("IndentWithOptions(%+v) error: %v", tt.opts, err)
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("IndentWithOptions(%+v) =\n%s\nwant\n%s", tt.opts, got, tt.want)
+		}
+	}
+
+	opts := IndentOptions{Indent: "  ", InlineWidth: 40}
+	val := map[string]any{"a": []any{1.0, "x"}, "b": map[string]any{}}
+	got, err := MarshalIndentWithOptions(val, opts)
+	if err != nil {
+		t.Fatalf("MarshalIndentWithOptions error: %v", err)
+	}
+	if want := `{"a": [1, "x"], "b": {}}`; string(got) != want {
+		t.Errorf("MarshalIndentWithOptions = %s, want %s", got, want)
+	}
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.SetIndentOptions(IndentOptions{Indent: "  ", InlineScalarArrays: true})
+	if err := enc.Encode([]any{[]int{1, 2}, "s"}); err != nil {
+		t.Fatalf("Encode error: %v", err)
+	}
+	if want := "[\n  [1, 2],\n  \"s\"\n]"; buf.String() != want {
+		t.Errorf("Encode with SetIndentOptions = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestIndentColors(t *testing.T) {
+	clr := Colors{Key: "<k>", String: "<s>", Number: "<n>", Bool: "<b>"}
+	var buf bytes.Buffer
+	err := IndentWithOptions(&buf, []byte(`{"a":["x",1.5,true,null],"b":{}}`), IndentOptions{Indent: " ", Colors: clr})
+	if err != nil {
+		t.Fatalf("IndentWithOptions error: %v", err)
+	}
+	const reset = "\x1b[0m"
+	want := "{\n <k>\"a\"" + reset + ": [\n  <s>\"x\"" + reset + ",\n  <n>1.5" + reset + ",\n  <b>true" + reset + ",\n  null\n ],\n <k>\"b\"" + reset + ": {}\n}"
+	if got := buf.String(); got != want {
+		t.Errorf("IndentWithOptions =\n%q\nwant\n%q", got, want)
+	}
+
+	buf.Reset()
+	enc := NewEncoder(&buf)
+	enc.SetIndentOptions(IndentOptions{Colors: DefaultColors()})
+	if err := enc.Encode(map[string]any{"k": -2}); err != nil {
+		t.Fatalf("Encode error: %v", err)
+	}
+	if want := "{\x1b[34;1m\"k\"" + reset + ":\x1b[39m-2" + reset + "}"; buf.String() != want {
+		t.Errorf("Encode = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestWriteToken(t *testing.T) {
+	src := []byte(`{"a": [1, "<b>", true, null, {}, []], "c": {"d": 2.5}, "e": [{"f": []}]}`)
+	for _, indent := range []string{"", "\t"} {
+		var buf, want bytes.Buffer
+		dec := NewDecoder(bytes.NewReader(src))
+		dec.UseNumber()
+		enc := NewEncoder(&buf)
+		enc.SetIndent("", indent)
+		for {
+			tok, err := dec.Token()
+			if err != nil {
+				break
+			}
+			if err := enc.WriteToken(tok); err != nil {
+				t.Fatalf("WriteToken(%v): %v", tok, err)
+			}
+		}
+		if indent == "" {
+			Compact(&want, src)
+		} else {
+			Indent(&want, src, "", indent)
+		}
+		if buf.String() != want.String() {
+			t.Errorf("indent %q:\ngot  %s\nwant %s", indent, buf.String(), want.String())
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	steps := []func() error{
+		func() error { return enc.WriteToken(Delim('{')) },
+		func() error { return enc.WriteValue(RawMessage(` "k" `)) },
+		func() error { return enc.WriteValue(RawMessage(`[1, 2,  {"<x>": 3}]`)) },
+		func() error { return enc.WriteToken("n") },
+		func() error { return enc.WriteToken(math.Pi) },
+		func() error { return enc.WriteToken(Delim('}')) },
+		func() error { return enc.WriteToken(false) },
+	}
+	for idx, step := range steps {
+		if err := step(); err != nil {
+			t.Fatalf("step %d: %v", idx, err)
+		}
+	}
+	if want := `{"k":[1,2,{"<x>":3}],"n":3.141592653589793}` + "\nfalse"; buf.String() != want {
+		t.Errorf("got %s, want %s", buf.String(), want)
+	}
+
+	buf.Reset()
+	enc = NewEncoder(&buf)
+	for _, tok := range []Token{Delim('['), -3, int64(math.MinInt64), uint8(7), uint64(math.MaxUint64), float32(0.5), Delim(']')} {
+		if err := enc.WriteToken(tok); err != nil {
+			t.Fatalf("WriteToken(%v): %v", tok, err)
+		}
+	}
+	if want := `[-3,-9223372036854775808,7,18446744073709551615,0.5]`; buf.String() != want {
+		t.Errorf("got %s, want %s", buf.String(), want)
+	}
+
+	invalid := [][]any{
+		{Delim(']')},
+		{Delim('['), Delim('}')},
+		{Delim('{'), 1.0},
+		{Delim('{'), Delim('[')},
+		{Delim('{'), "k", Delim(']')},
+		{Delim('{'), 1},
+		{Delim('['), []int{1}},
+		{Delim('['), RawMessage(`1 2`)},
+		{Delim('{'), RawMessage(`1`)},
+		{Delim('['), RawMessage(`]`)},
+		{Number("1.2.3")},
+	}
+	for _, toks := range invalid {
+		enc := NewEncoder(&buf)
+		var err error
+		for _, tok := range toks {
+			if raw, ok := tok.(RawMessage); ok {
+				err = enc.WriteValue(raw)
+			} else {
+				err = enc.WriteToken(tok)
+			}
+		}
+		if err == nil {
+			t.Errorf("%v: no error", toks)
+		}
+	}
+}
+
+func TestStreams(t *testing.T) {
+	doc := `{"a": [1, -2.5e3, "<é>", true, null, {}, [ ]], "b": {"c": [{"d": "x"}]}}`
+	src := doc + "\n\"" + strings.Repeat("long ", 1000) + "\"\n" + doc
+	var want bytes.Buffer
+	dec := NewDecoder(strings.NewReader(src))
+	for {
+		var raw RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			break
+		}
+		if want.Len() > 0 {
+			want.WriteByte('\n')
+		}
+		Indent(&want, raw, "> ", "\t")
+	}
+	var got bytes.Buffer
+	if err := IndentStream(&got, iotest.OneByteReader(strings.NewReader(src)), "> ", "\t"); err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != want.String() {
+		t.Errorf("IndentStream:\ngot  %s\nwant %s", got.String(), want.String())
+	}
+	got.Reset()
+	want.Reset()
+	Compact(&want, []byte(doc))
+	if err := CompactStream(&got, strings.NewReader(doc)); err != nil || got.String() != want.String() {
+		t.Errorf("CompactStream = %s, %v, want %s", got.String(), err, want.String())
+	}
+
+	for _, src := range []string{``, ` `, `[1,]`, `{"a" 1}`, `[1] ]`, `"\x01"`, `-`} {
+		if err := CompactStream(io.Discard, strings.NewReader(src)); err == nil {
+			t.Errorf("CompactStream(%q): no error", src)
+		}
+		if ValidReader(strings.NewReader(src)) {
+			t.Errorf("ValidReader(%q) = true", src)
+		}
+	}
+	if !ValidReader(iotest.OneByteReader(strings.NewReader(src))) {
+		t.Errorf("ValidReader = false, want true")
+	}
+	if ValidReader(iotest.TimeoutReader(strings.NewReader(`[1, 2]`))) {
+		t.Errorf("ValidReader with a read error = true")
+	}
+}
+
+func TestIndentEmpty(t *testing.T) {
+	// without a prefix and an indent, nothing is laid out, as before IndentOptions.
+	src := []byte(`{"a": [1, 2], "b": {"c": null}}`)
+	var got, want bytes.Buffer
+	if err := Indent(&got, src, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	Compact(&want, src)
+	if got.String() != want.String() {
+		t.Errorf("Indent = %q, want %q", got.String(), want.String())
+	}
+	val := map[string]any{"a": []any{1, 2}}
+	gotM, err := MarshalIndent(val, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"a":[1,2]}`; string(gotM) != want {
+		t.Errorf("MarshalIndent = %s, want %s", gotM, want)
+	}
+
+	// options with only an indent lay out as Indent does.
+	got.Reset()
+	want.Reset()
+	Indent(&want, src, "", "  ")
+	if err := IndentWithOptions(&got, src, IndentOptions{Indent: "  "}); err != nil || got.String() != want.String() {
+		t.Errorf("IndentWithOptions = %q, %v, want %q", got.String(), err, want.String())
+	}
+}
@@ -3,6 +3,7 @@ package sonnet
 import (
 	"reflect"
 	"sort"
+	"strconv"
 	"strings"
 	"unicode"
 )
@@ -14,6 +15,10 @@ type (
 		fldsMap *perf
 		caseMap *perf
 		flg     flag
+		err     error
+		// unk is the map that collects unknown object members,
+		// its dec and enc are for the elements of the map.
+		unk *field
 	}
 	field struct {
 		dec      decoder
@@ -24,6 +29,7 @@ type (
 		nameHTML []byte
 		typ      reflect.Type
 		idxs     []int
+		flt      floatFormat
 	}
 	byIdx []field
 )
@@ -34,6 +40,9 @@ const (
 	flagTag
 	flagString
 	flagOmitempty
+	flagLenient
+	flagInline
+	flagUnknown
 )
 
 func (by byIdx) Len() int {
@@ -62,6 +71,8 @@ func (by byIdx) Less(fst, sec int) bool {
 func makeFields(typ reflect.Type) fields {
 	var flds, currFlds, nextFlds []field
 	var currCnt, nextCnt map[reflect.Type]int
+	var unk *field
+	var err error
 
 	nextFlds = append(nextFlds, field{typ: typ})
 	vis := make(map[reflect.Type]struct{})
@@ -117,6 +128,7 @@ func makeFields(typ reflect.Type) fields {
 				}
 
 				var flg flag
+				var flt floatFormat
 				for idx := range spl {
 					if spl[idx] == "string" {
 						flg |= flagString
@@ -124,15 +136,48 @@ func makeFields(typ reflect.Type) fields {
 					if spl[idx] == "omitempty" {
 						flg |= flagOmitempty
 					}
+					if spl[idx] == "lenient" {
+						flg |= flagLenient
+					}
+					if spl[idx] == "inline" {
+						flg |= flagInline
+					}
+					if spl[idx] == "unknown" {
+						flg |= flagUnknown
+					}
+					if form, ok := strings.CutPrefix(spl[idx], "format:"); ok && err == nil {
+						flt, ok = parseFloatFormat(form)
+						if !ok {
+							err = fieldError("invalid format " + strconv.Quote(form) + " on field " + str.Name)
+						} else if typ.Kind() != reflect.Float32 && typ.Kind() != reflect.Float64 {
+							err = fieldError("format option on non-floating point field " + str.Name)
+						}
+					}
 				}
 
 				const accept = reflect.Float64 - reflect.Bool
 				if typ.Kind()-reflect.Bool > accept && typ.Kind() != reflect.String {
 					flg &^= flagString
 				}
+				if typ.Kind()-reflect.Bool > accept || flg&flagString != 0 {
+					flg &^= flagLenient
+				}
+
+				if flg&(flagInline|flagUnknown) != 0 && str.Type.Kind() == reflect.Map && str.Type.Key().Kind() == reflect.String {
+					// the shallowest one collects unknown members.
+					if unk == nil {
+						unk = &field{
+							name: str.Name,
+							idxs: idxs,
+							typ:  str.Type,
+						}
+					}
+					continue
+				}
+				inline := flg&flagInline != 0 && typ.Kind() == reflect.Struct
 
 				// Record found field and index sequence.
-				if name != "" || !str.Anonymous || typ.Kind() != reflect.Struct {
+				if !inline && (name != "" || !str.Anonymous || typ.Kind() != reflect.Struct) {
 					if name != "" {
 						flg |= flagTag
 					} else {
@@ -143,6 +188,7 @@ func makeFields(typ reflect.Type) fields {
 						idxs: idxs,
 						typ:  typ,
 						flg:  flg,
+						flt:  flt,
 					}
 					flds = append(flds, app)
 					if currCnt[fld.typ] > 1 {
@@ -221,7 +267,11 @@ func makeFields(typ reflect.Type) fields {
 		az95 = az95 && isAZ95(fld.name)
 		flw := followType(typ, fld.idxs)
 		fld.dec, _ = decs.get(flw)
-		fld.enc, _ = encs.get(flw)
+		if fld.flt.verb != 0 {
+			fld.enc = compileFloatFormatEncoder(flw, fld.flt)
+		} else {
+			fld.enc, _ = encs.get(flw)
+		}
 
 		fld.nameJSON = make([]byte, 0, len(fld.name)+2)
 		fld.nameJSON = append(fld.nameJSON, ',')
@@ -238,6 +288,8 @@ func makeFields(typ reflect.Type) fields {
 		flds:    flds,
 		fldsMap: makePerf(len(flds)),
 		caseMap: makePerf(len(flds)),
+		err:     err,
+		unk:     unk,
 	}
 
 	tups := make([]tuple, 0, len(flds))
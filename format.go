@@ -0,0 +1,443 @@
+package sonnet
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+)
+
+type (
+	// FormatOptions configures Format.
+	FormatOptions struct {
+		// Indent is written once for each level of nesting.
+		// The empty string means a tab.
+		Indent string
+		// Relaxed accepts the extensions of Decoder.AllowRelaxed.
+		// Comments are kept; trailing commas are removed.
+		// Other tokens are written as they are, quotes included.
+		Relaxed bool
+		// SortKeys sorts the members of every object by their keys.
+		// Comments and blank lines move with the member they precede.
+		SortKeys bool
+		// ArrayWidth, if positive, writes an array of numbers, strings,
+		// booleans and nulls on one line when that line, brackets
+		// included, is at most ArrayWidth bytes long.
+		ArrayWidth int
+	}
+	formatter struct {
+		dec  Decoder
+		opts FormatOptions
+		dst  []byte
+	}
+	fmtNode struct {
+		kind  byte       // '{', '[', or 0 for the other values.
+		text  []byte     // the value as written, or the key of a member.
+		name  string     // the unquoted key of a member, for sorting.
+		val   *fmtNode   // the value of a member.
+		elms  []*fmtNode // the elements of an array, or the members of an object.
+		lead  fmtGap     // spaces and comments before the node.
+		trail []fmtComment
+		end   fmtGap // spaces and comments before the closing bracket.
+	}
+	fmtGap struct {
+		cmts []fmtComment
+		nl   int // newlines between the last comment, or the previous token, and the next token.
+	}
+	fmtComment struct {
+		text []byte
+		nl   int // newlines between the previous comment, or token, and this one.
+	}
+)
+
+// Format returns src formatted in a canonical way, like gofmt does for Go
+// source code. Every element of an array and every member of an object
+// starts on its own line, indented with opts.Indent, and a colon is followed
+// by a space. Unlike Indent, the comments of relaxed input and single blank
+// lines between elements are kept. The output ends with a newline.
+func Format(src []byte, opts FormatOptions) ([]byte, error) {
+	if opts.Indent == "" {
+		opts.Indent = "\t"
+	}
+	fmtr := formatter{
+		dec:  Decoder{buf: src},
+		opts: opts,
+		dst:  make([]byte, 0, len(src)+len(src)/4),
+	}
+	if opts.Relaxed {
+		fmtr.dec.opt |= optRelaxed
+	}
+	lead, err := fmtr.gap()
+	if err != nil {
+		return nil, err
+	}
+	head, err := fmtr.next()
+	if err != nil {
+		return nil, err
+	}
+	nd, err := fmtr.parse(head)
+	if err != nil {
+		return nil, err
+	}
+	after, err := fmtr.gap()
+	if err != nil {
+		return nil, err
+	}
+	if fmtr.dec.pos < len(src) {
+		return nil, fmtr.dec.errSyntax("invalid character " + strconv.QuoteRune(rune(src[fmtr.dec.pos])) + " after top-level value")
+	}
+	nd.trail, after = splitGap(after)
+
+	for _, cmt := range lead.cmts {
+		fmtr.dst = append(fmtr.dst, cmt.text...)
+		fmtr.dst = append(fmtr.dst, '\n')
+	}
+	fmtr.print(nd, 0)
+	fmtr.printTrail(nd.trail)
+	for _, cmt := range after.cmts {
+		fmtr.dst = append(fmtr.dst, '\n')
+		fmtr.dst = append(fmtr.dst, cmt.text...)
+	}
+	return append(fmtr.dst, '\n'), nil
+}
+
+// gap reads the spaces and comments before the next token.
+func (fmtr *formatter) gap() (fmtGap, error) {
+	var gap fmtGap
+	buf := fmtr.dec.buf
+	for fmtr.dec.pos < len(buf) {
+		switch buf[fmtr.dec.pos] {
+		case '\n':
+			gap.nl++
+			fmtr.dec.pos++
+		case ' ', '\t', '\r':
+			fmtr.dec.pos++
+		case '/':
+			start := fmtr.dec.pos
+			if !fmtr.opts.Relaxed || start+1 >= len(buf) {
+				return gap, nil
+			}
+			end := start + 2
+			switch buf[start+1] {
+			case '/':
+				idx := bytes.IndexByte(buf[end:], '\n')
+				if idx < 0 {
+					end = len(buf)
+				} else {
+					end += idx
+				}
+			case '*':
+				idx := bytes.Index(buf[end:], []byte("*/"))
+				if idx < 0 {
+					fmtr.dec.pos = len(buf)
+					return gap, fmtr.dec.errSyntax("comment not terminated")
+				}
+				end += idx + len("*/")
+			default:
+				return gap, nil
+			}
+			gap.cmts = append(gap.cmts, fmtComment{text: buf[start:end], nl: gap.nl})
+			gap.nl = 0
+			fmtr.dec.pos = end
+		default:
+			return gap, nil
+		}
+	}
+	return gap, nil
+}
+
+// splitGap splits gap into the comments on the same line as the previous token,
+// and the rest.
+func splitGap(gap fmtGap) ([]fmtComment, fmtGap) {
+	var idx int
+	for idx < len(gap.cmts) && gap.cmts[idx].nl == 0 {
+		idx++
+	}
+	trail := gap.cmts[:idx:idx]
+	gap.cmts = gap.cmts[idx:]
+	return trail, gap
+}
+
+// joinGaps returns the gap made of fst followed by sec,
+// which are separated by a comma.
+func joinGaps(fst, sec fmtGap) fmtGap {
+	if len(sec.cmts) == 0 {
+		fst.nl += sec.nl
+		return fst
+	}
+	sec.cmts[0].nl += fst.nl
+	fst.cmts = append(fst.cmts, sec.cmts...)
+	fst.nl = sec.nl
+	return fst
+}
+
+func (fmtr *formatter) next() (byte, error) {
+	if fmtr.dec.pos >= len(fmtr.dec.buf) {
+		return 0, fmtr.dec.errEOF("unexpected EOF reading a byte")
+	}
+	head := fmtr.dec.buf[fmtr.dec.pos]
+	fmtr.dec.pos++
+	return head, nil
+}
+
+func (fmtr *formatter) parse(head byte) (*fmtNode, error) {
+	dec := &fmtr.dec
+	if head == '{' || head == '[' {
+		err := dec.inc()
+		if err != nil {
+			return nil, err
+		}
+		nd := &fmtNode{kind: head}
+		if head == '{' {
+			err = fmtr.parseObject(nd)
+		} else {
+			err = fmtr.parseArray(nd)
+		}
+		if err != nil {
+			return nil, err
+		}
+		dec.dep--
+		return nd, nil
+	}
+	start := dec.pos - 1
+	var err error
+	switch {
+	case dec.isQuote(head):
+		err = dec.eatString(head)
+	case len(keywords[head]) > 0:
+		word := keywords[head]
+		var part []byte
+		part, err = dec.readn(len(word))
+		if err == nil && string(part) != word {
+			err = dec.buildErrSyntax(head, word, part)
+		}
+	case dec.opt&optRelaxed != 0 && dec.isHex(head):
+		_, _, err = dec.readHex(head)
+		if err == strconv.ErrRange {
+			err = nil // written as is.
+		}
+	case head-'0' < 10 || head == '-':
+		err = dec.eatNumber()
+	default:
+		err = dec.errSyntax("invalid character " + strconv.QuoteRune(rune(head)) + " looking for beginning of value")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &fmtNode{text: dec.buf[start:dec.pos]}, nil
+}
+
+func (fmtr *formatter) parseArray(nd *fmtNode) error {
+	pend, err := fmtr.gap()
+	if err != nil {
+		return err
+	}
+	for {
+		head, err := fmtr.next()
+		if err != nil {
+			return err
+		}
+		if head == ']' && (len(nd.elms) == 0 || fmtr.opts.Relaxed) {
+			nd.end = pend
+			return nil
+		}
+		elm, err := fmtr.parse(head)
+		if err != nil {
+			return err
+		}
+		elm.lead = pend
+		nd.elms = append(nd.elms, elm)
+
+		after, err := fmtr.gap()
+		if err != nil {
+			return err
+		}
+		head, err = fmtr.next()
+		if err != nil {
+			return err
+		}
+		if head == ']' {
+			elm.trail, nd.end = splitGap(after)
+			return nil
+		}
+		if head != ',' {
+			return fmtr.dec.errSyntax("invalid character " + strconv.QuoteRune(rune(head)) + " after array element")
+		}
+		more, err := fmtr.gap()
+		if err != nil {
+			return err
+		}
+		after = joinGaps(after, more)
+		elm.trail, pend = splitGap(after)
+	}
+}
+
+func (fmtr *formatter) parseObject(nd *fmtNode) error {
+	dec := &fmtr.dec
+	pend, err := fmtr.gap()
+	if err != nil {
+		return err
+	}
+	for {
+		head, err := fmtr.next()
+		if err != nil {
+			return err
+		}
+		if head == '}' && (len(nd.elms) == 0 || fmtr.opts.Relaxed) {
+			nd.end = pend
+			return nil
+		}
+		start := dec.pos - 1
+		key, err := dec.readKey(head)
+		if err != nil {
+			return err
+		}
+		mbr := &fmtNode{
+			text: dec.buf[start:dec.pos],
+			name: string(key),
+			lead: pend,
+		}
+		nd.elms = append(nd.elms, mbr)
+
+		// comments around the colon are moved before the member.
+		for idx := 0; idx < 2; idx++ {
+			gap, err := fmtr.gap()
+			if err != nil {
+				return err
+			}
+			if len(gap.cmts) > 0 {
+				// they take the place of the key, after its blank lines.
+				gap.cmts[0].nl = mbr.lead.nl
+				mbr.lead.cmts = append(mbr.lead.cmts, gap.cmts...)
+				mbr.lead.nl = 0
+			}
+			head, err = fmtr.next()
+			if err != nil {
+				return err
+			}
+			if idx == 0 && head != ':' {
+				return dec.errSyntax("invalid character " + strconv.QuoteRune(rune(head)) + " after object key")
+			}
+		}
+		mbr.val, err = fmtr.parse(head)
+		if err != nil {
+			return err
+		}
+
+		after, err := fmtr.gap()
+		if err != nil {
+			return err
+		}
+		head, err = fmtr.next()
+		if err != nil {
+			return err
+		}
+		if head == '}' {
+			mbr.trail, nd.end = splitGap(after)
+			return nil
+		}
+		if head != ',' {
+			return dec.errSyntax("invalid character " + strconv.QuoteRune(rune(head)) + " after object key:value pair")
+		}
+		more, err := fmtr.gap()
+		if err != nil {
+			return err
+		}
+		after = joinGaps(after, more)
+		mbr.trail, pend = splitGap(after)
+	}
+}
+
+func (fmtr *formatter) print(nd *fmtNode, dep int) {
+	if nd.kind == 0 {
+		fmtr.dst = append(fmtr.dst, nd.text...)
+		return
+	}
+	closer := byte(']')
+	if nd.kind == '{' {
+		closer = '}'
+		if fmtr.opts.SortKeys {
+			sort.SliceStable(nd.elms, func(fst, sec int) bool {
+				return nd.elms[fst].name < nd.elms[sec].name
+			})
+		}
+	}
+	if len(nd.elms) == 0 && len(nd.end.cmts) == 0 {
+		fmtr.dst = append(fmtr.dst, nd.kind, closer)
+		return
+	}
+	if fmtr.inline(nd) {
+		fmtr.dst = append(fmtr.dst, nd.kind)
+		for idx, elm := range nd.elms {
+			if idx > 0 {
+				fmtr.dst = append(fmtr.dst, ',', ' ')
+			}
+			fmtr.dst = append(fmtr.dst, elm.text...)
+		}
+		fmtr.dst = append(fmtr.dst, closer)
+		return
+	}
+	fmtr.dst = append(fmtr.dst, nd.kind)
+	for idx, elm := range nd.elms {
+		fmtr.printLead(elm.lead, dep+1, idx == 0)
+		if nd.kind == '{' {
+			fmtr.dst = append(fmtr.dst, elm.text...)
+			fmtr.dst = append(fmtr.dst, ':', ' ')
+			fmtr.print(elm.val, dep+1)
+		} else {
+			fmtr.print(elm, dep+1)
+		}
+		if idx < len(nd.elms)-1 {
+			fmtr.dst = append(fmtr.dst, ',')
+		}
+		fmtr.printTrail(elm.trail)
+	}
+	for idx, cmt := range nd.end.cmts {
+		fmtr.newline(dep+1, cmt.nl >= 2 && (idx > 0 || len(nd.elms) > 0))
+		fmtr.dst = append(fmtr.dst, cmt.text...)
+	}
+	fmtr.newline(dep, false)
+	fmtr.dst = append(fmtr.dst, closer)
+}
+
+// inline reports whether the array nd fits on one line, see FormatOptions.ArrayWidth.
+func (fmtr *formatter) inline(nd *fmtNode) bool {
+	if nd.kind != '[' || fmtr.opts.ArrayWidth <= 0 || len(nd.end.cmts) > 0 {
+		return false
+	}
+	width := len("[]") + len(", ")*(len(nd.elms)-1)
+	for _, elm := range nd.elms {
+		if elm.kind != 0 || len(elm.lead.cmts) > 0 || len(elm.trail) > 0 {
+			return false
+		}
+		width += len(elm.text)
+	}
+	return width <= fmtr.opts.ArrayWidth
+}
+
+// printLead writes the newline, the blank line and the comments before an element.
+// a blank line is never written right after an opening bracket.
+func (fmtr *formatter) printLead(gap fmtGap, dep int, first bool) {
+	for _, cmt := range gap.cmts {
+		fmtr.newline(dep, cmt.nl >= 2 && !first)
+		fmtr.dst = append(fmtr.dst, cmt.text...)
+		first = false
+	}
+	fmtr.newline(dep, gap.nl >= 2 && !first)
+}
+
+func (fmtr *formatter) printTrail(cmts []fmtComment) {
+	for _, cmt := range cmts {
+		fmtr.dst = append(fmtr.dst, ' ')
+		fmtr.dst = append(fmtr.dst, cmt.text...)
+	}
+}
+
+func (fmtr *formatter) newline(dep int, blank bool) {
+	if blank {
+		fmtr.dst = append(fmtr.dst, '\n')
+	}
+	fmtr.dst = append(fmtr.dst, '\n')
+	for idx := 0; idx < dep; idx++ {
+		fmtr.dst = append(fmtr.dst, fmtr.opts.Indent...)
+	}
+}
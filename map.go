@@ -78,22 +78,20 @@ func compileMapDecoder(typ reflect.Type) decoder {
 		if val.IsNil() {
 			val.Set(reflect.MakeMap(val.Type()))
 		}
+		var mid bool
 		for {
 			dec.eatSpaces()
 			if dec.pos >= len(dec.buf) && !dec.fill() {
-				return dec.errSyntax("unexpected EOF reading a byte")
+				return dec.errEOF("unexpected EOF reading a byte")
 			}
 			head = dec.buf[dec.pos]
 			dec.pos++
-			if head == '}' && val.Len() == 0 {
+			if head == '}' && (!mid || dec.opt&optRelaxed != 0) {
 				dec.dep--
 				return nil
 			}
-			if head != '"' {
-				return dec.errSyntax("invalid character " + strconv.QuoteRune(rune(head)) + " looking for beginning of object key string")
-			}
 
-			slice, err := dec.readString()
+			slice, err := dec.readKey(head)
 			if err != nil {
 				return err
 			}
@@ -104,7 +102,7 @@ func compileMapDecoder(typ reflect.Type) decoder {
 
 			dec.eatSpaces()
 			if dec.pos >= len(dec.buf) && !dec.fill() {
-				return dec.errSyntax("unexpected EOF reading a byte")
+				return dec.errEOF("unexpected EOF reading a byte")
 			}
 			head = dec.buf[dec.pos]
 			dec.pos++
@@ -114,7 +112,7 @@ func compileMapDecoder(typ reflect.Type) decoder {
 
 			dec.eatSpaces()
 			if dec.pos >= len(dec.buf) && !dec.fill() {
-				return dec.errSyntax("unexpected EOF reading a byte")
+				return dec.errEOF("unexpected EOF reading a byte")
 			}
 			head = dec.buf[dec.pos]
 			dec.pos++
@@ -128,7 +126,7 @@ func compileMapDecoder(typ reflect.Type) decoder {
 
 			dec.eatSpaces()
 			if dec.pos >= len(dec.buf) && !dec.fill() {
-				return dec.errSyntax("unexpected EOF reading a byte")
+				return dec.errEOF("unexpected EOF reading a byte")
 			}
 			head = dec.buf[dec.pos]
 			dec.pos++
@@ -139,6 +137,7 @@ func compileMapDecoder(typ reflect.Type) decoder {
 			if head != ',' {
 				return dec.errSyntax("invalid character " + strconv.QuoteRune(rune(head)) + " after object key:value pair")
 			}
+			mid = true
 		}
 	}
 }
@@ -183,7 +182,7 @@ func compileMapKeyDecoder(typ reflect.Type) mapDecoder {
 				err = &UnmarshalTypeError{
 					Value:  "number " + string(src),
 					Type:   typ,
-					Offset: dec.InputOffset() - int64(len(src)),
+					Offset: dec.offset(dec.pos - len(src)),
 				}
 				return reflect.Value{}, err
 			}
@@ -201,7 +200,7 @@ func compileMapKeyDecoder(typ reflect.Type) mapDecoder {
 				err = &UnmarshalTypeError{
 					Value:  "number " + string(src),
 					Type:   typ,
-					Offset: dec.InputOffset() - int64(len(src)),
+					Offset: dec.offset(dec.pos - len(src)),
 				}
 				return reflect.Value{}, err
 			}
@@ -309,53 +308,67 @@ func compileMapEncoder(typ reflect.Type) encoder {
 				}
 			}
 		}
-		if noesc {
-			slices.SortFunc(prs, func(fst, sec *pair) int {
-				if fst.neg && !sec.neg {
-					return -1
-				}
-				if !fst.neg && sec.neg {
-					return 1
-				}
-				if fst.u64 < sec.u64 {
-					return -1
-				}
-				if fst.u64 > sec.u64 {
-					return 1
-				}
-				return 0
-			})
-		} else {
-			slices.SortFunc(prs, func(fst, sec *pair) int {
-				return strings.Compare(fst.str, sec.str)
-			})
+		if !enc.opts.UnorderedMaps {
+			sortPairs(prs, noesc)
 		}
-
 		dst = append(dst, '{')
-		var mid bool
-		for _, pr := range prs {
-			if mid {
-				dst = append(dst, ',')
+		dst, err := appendPairs(dst, prs, noesc, false, fnc, enc)
+		if err != nil {
+			return nil, err
+		}
+		enc.level--
+		return append(dst, '}'), nil
+	}
+}
+
+// sortPairs sorts prs by key, by value when the keys are integers.
+func sortPairs(prs []*pair, noesc bool) {
+	if noesc {
+		slices.SortFunc(prs, func(fst, sec *pair) int {
+			if fst.neg && !sec.neg {
+				return -1
 			}
-			if noesc {
-				dst = append(dst, '"')
-				dst = append(dst, fmtInt(pr.u64, pr.neg)...) // never pr.str!
-				dst = append(dst, '"')
-			} else {
-				dst = appendString(dst, pr.str, enc.html)
+			if !fst.neg && sec.neg {
+				return 1
 			}
-			dst = append(dst, ':')
-			var err error
-			dst, err = fnc(dst, pr.elm, enc)
-			if err != nil {
-				return nil, err
+			if fst.u64 < sec.u64 {
+				return -1
 			}
-			mid = true
-		}
+			if fst.u64 > sec.u64 {
+				return 1
+			}
+			return 0
+		})
+	} else {
+		slices.SortFunc(prs, func(fst, sec *pair) int {
+			return strings.Compare(fst.str, sec.str)
+		})
+	}
+}
 
-		enc.level--
-		return append(dst, '}'), nil
+// appendPairs writes prs as the members of an object, the elements with fnc.
+// mid tells whether a member was written before them.
+func appendPairs(dst []byte, prs []*pair, noesc, mid bool, fnc encoder, enc *Encoder) ([]byte, error) {
+	for _, pr := range prs {
+		if mid {
+			dst = append(dst, ',')
+		}
+		if noesc {
+			dst = append(dst, '"')
+			dst = append(dst, fmtInt(pr.u64, pr.neg)...) // never pr.str!
+			dst = append(dst, '"')
+		} else {
+			dst = appendString(dst, pr.str, enc.html)
+		}
+		dst = append(dst, ':')
+		var err error
+		dst, err = fnc(dst, pr.elm, enc)
+		if err != nil {
+			return nil, err
+		}
+		mid = true
 	}
+	return dst, nil
 }
 
 func compileMapKeyEncoder(typ reflect.Type) mapEncoder {
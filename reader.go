@@ -6,6 +6,8 @@ import (
 	"github.com/sugawarayuuta/sonnet/internal/arith"
 	"github.com/sugawarayuuta/sonnet/internal/mem"
 	"io"
+	"math"
+	"math/big"
 	"reflect"
 	"strconv"
 	"unicode/utf16"
@@ -20,7 +22,27 @@ type (
 		dep       int
 		digit     int
 		inp       io.Reader
+		err       error
 		opt       byte
+		tr        *transcoder
+		// the grammar state of Token, and the arrays and objects it is in.
+		state byte
+		stack []frame
+	}
+	// frame is an array or an object that Token is in.
+	frame struct {
+		kind Kind
+		// the grammar state to return to after it.
+		prev byte
+		// the number of elements or members started, and the last key.
+		cnt int
+		key []byte
+	}
+	// A ReadError is returned when the underlying reader of a Decoder fails.
+	// Offset is the number of bytes read from it before the failure.
+	ReadError struct {
+		Offset int64
+		Err    error
 	}
 	accept struct {
 		hi, lo byte
@@ -76,10 +98,18 @@ const (
 	optKeep byte = 1 << iota
 	optUnknownFields
 	optNumber
+	optNonFinite
+	optInt64
+	optQuoted
+	optRelaxed
+	optDetect
 )
 
 const (
 	maxDep = 10000
+	// the number of reads in a row without data after which fill gives up,
+	// the same as bufio.
+	maxEmptyReads = 100
 )
 
 func (dec *Decoder) inc() error {
@@ -91,7 +121,46 @@ func (dec *Decoder) inc() error {
 }
 
 func (dec *Decoder) errSyntax(msg string) error {
-	return &SyntaxError{msg: msg, Offset: int64(dec.prev + dec.pos)}
+	return &SyntaxError{msg: msg, Offset: dec.offset(dec.pos)}
+}
+
+// errEOF is like errSyntax for the unexpected end of the input,
+// but returns the error of the reader instead when it ended because the reader failed.
+func (dec *Decoder) errEOF(msg string) error {
+	if err, ok := dec.err.(*SyntaxError); ok {
+		return err
+	}
+	if dec.err != nil && dec.err != io.EOF {
+		// drop the rest, every later call fails the same way.
+		dec.pos = len(dec.buf)
+		return dec.errRead()
+	}
+	return dec.errSyntax(msg)
+}
+
+// errCut returns the error of the reader when it failed right after the
+// top-level number starting with head, which might have gone on.
+func (dec *Decoder) errCut(head byte) error {
+	if len(dec.stack) == 0 && (head == '-' || head-'0' < 10) && dec.pos >= len(dec.buf) && dec.err != nil && dec.err != io.EOF {
+		return dec.errEOF("unexpected EOF reading a number")
+	}
+	return nil
+}
+
+func (dec *Decoder) errRead() error {
+	off := dec.offset(len(dec.buf))
+	if dec.tr != nil {
+		off += int64(len(dec.tr.raw))
+	}
+	return &ReadError{Offset: off, Err: dec.err}
+}
+
+func (err *ReadError) Error() string {
+	return "sonnet: read error after " + strconv.FormatInt(err.Offset, 10) + " bytes: " + err.Err.Error()
+}
+
+func (err *ReadError) Unwrap() error {
+	return err.Err
 }
 
 func (dec *Decoder) errUnmarshalType(head byte, typ reflect.Type) error {
@@ -102,7 +171,7 @@ func (dec *Decoder) errUnmarshalType(head byte, typ reflect.Type) error {
 	}
 	var val string
 	switch head {
-	case '"':
+	case '"', '\'':
 		val = "string"
 	case '{':
 		val = "object"
@@ -118,32 +187,55 @@ func (dec *Decoder) errUnmarshalType(head byte, typ reflect.Type) error {
 	return &UnmarshalTypeError{Value: val, Type: typ, Offset: bef}
 }
 
+// fill reads more input into the buffer, reporting whether it got any.
+// the first error of the reader is kept in dec.err, after the data
+// returned with it is used, and no more reads happen after that.
 func (dec *Decoder) fill() bool {
-	if dec.inp == nil {
+	if dec.inp == nil || dec.err != nil {
 		return false
 	}
-	if cap(dec.buf)-len(dec.buf) >= 1<<10 {
-		read, err := dec.inp.Read(dec.buf[len(dec.buf):cap(dec.buf)])
+	if cap(dec.buf)-len(dec.buf) < 1<<10 {
+		dec.refill()
+	}
+	for cnt := 0; cnt < maxEmptyReads; cnt++ {
+		read, err := dec.read(dec.buf[len(dec.buf):cap(dec.buf)])
 		dec.buf = dec.buf[:len(dec.buf)+read]
-		return err == nil && read != 0
+		if err != nil {
+			dec.err = err
+		}
+		if read > 0 {
+			return true
+		}
+		if err != nil {
+			return false
+		}
 	}
-	return dec.refill()
+	dec.err = io.ErrNoProgress
+	return false
 }
 
-func (dec *Decoder) refill() bool {
-	buf := mem.Get((cap(dec.buf) | 1) << 1)
+// refill moves the unread part of the buffer, or all of it when it's kept,
+// to the front. the buffer is replaced by one twice as large only when
+// that part takes more than half of it, so that long streams of small
+// values don't make it grow.
+func (dec *Decoder) refill() {
 	pos := dec.pos
 	if dec.opt&optKeep != 0 {
 		pos = 0
 	}
-	buf = buf[:copy(buf, dec.buf[pos:])]
+	if dec.tr != nil {
+		dec.tr.base += dec.tr.units(dec.buf[:pos])
+	}
 	dec.prev += pos
 	dec.pos -= pos
+	if len(dec.buf)-pos <= cap(dec.buf)>>1 {
+		dec.buf = dec.buf[:copy(dec.buf[:cap(dec.buf)], dec.buf[pos:])]
+		return
+	}
+	buf := mem.Get((cap(dec.buf) | 1) << 1)
+	buf = buf[:copy(buf, dec.buf[pos:])]
 	buf, dec.buf = dec.buf, buf
 	mem.Put(buf)
-	read, err := dec.inp.Read(dec.buf[len(dec.buf):cap(dec.buf)])
-	dec.buf = dec.buf[:len(dec.buf)+read]
-	return err == nil && read != 0
 }
 
 func (dec *Decoder) readn(n int) ([]byte, error) {
@@ -154,7 +246,7 @@ func (dec *Decoder) readn(n int) ([]byte, error) {
 			return buf, nil
 		}
 		if !dec.fill() {
-			return nil, dec.errSyntax("unexpected EOF reading a keyword")
+			return nil, dec.errEOF("unexpected EOF reading a keyword")
 		}
 	}
 }
@@ -164,6 +256,73 @@ func (dec *Decoder) eatSpaces() {
 	if dec.pos >= len(dec.buf) || 1<<dec.buf[dec.pos]&spaces != 0 {
 		dec.eatSpacesOut()
 	}
+	if dec.opt&optRelaxed != 0 {
+		dec.eatComments()
+	}
+}
+
+// eatComments skips // and /* */ comments and the spaces after them.
+// an unterminated block comment ends the input with a syntax error,
+// which is kept in dec.err in place of io.EOF.
+func (dec *Decoder) eatComments() {
+	const spaces uint64 = 1<<' ' | 1<<'\n' | 1<<'\r' | 1<<'\t'
+	for dec.makeSpace(2) && dec.buf[dec.pos] == '/' {
+		switch dec.buf[dec.pos+1] {
+		case '/':
+			dec.pos += 2
+			for (dec.pos < len(dec.buf) || dec.fill()) && dec.buf[dec.pos] != '\n' {
+				dec.pos++
+			}
+		case '*':
+			dec.pos += 2
+			for {
+				if !dec.makeSpace(2) {
+					dec.pos = len(dec.buf)
+					if dec.err == nil || dec.err == io.EOF {
+						dec.err = dec.errSyntax("comment not terminated")
+					}
+					return
+				}
+				if dec.buf[dec.pos] == '*' && dec.buf[dec.pos+1] == '/' {
+					dec.pos += 2
+					break
+				}
+				dec.pos++
+			}
+		default:
+			return
+		}
+		if dec.pos >= len(dec.buf) || 1<<dec.buf[dec.pos]&spaces != 0 {
+			dec.eatSpacesOut()
+		}
+	}
+}
+
+// isQuote reports whether head begins a string.
+// single-quoted strings are only allowed in the relaxed mode.
+func (dec *Decoder) isQuote(head byte) bool {
+	return head == '"' || head == '\'' && dec.opt&optRelaxed != 0
+}
+
+// readKey reads an object key starting with head. in the relaxed mode,
+// it may also be single-quoted, or unquoted with ASCII letters, digits, '_' and '$'.
+func (dec *Decoder) readKey(head byte) ([]byte, error) {
+	if dec.isQuote(head) {
+		return dec.readString(head)
+	}
+	if dec.opt&optRelaxed == 0 || !isIdent(head) || head-'0' < 10 {
+		return nil, dec.errSyntax("invalid character " + strconv.QuoteRune(rune(head)) + " looking for beginning of object key string")
+	}
+	dec.sub = append(dec.sub[:0], head)
+	for (dec.pos < len(dec.buf) || dec.fill()) && isIdent(dec.buf[dec.pos]) {
+		dec.sub = append(dec.sub, dec.buf[dec.pos])
+		dec.pos++
+	}
+	return dec.sub, nil
+}
+
+func isIdent(char byte) bool {
+	return char|0x20-'a' < 26 || char-'0' < 10 || char == '_' || char == '$'
 }
 
 func (dec *Decoder) eatSpacesOut() {
@@ -191,22 +350,22 @@ func (dec *Decoder) eatSpacesOut() {
 	}
 }
 
-func (dec *Decoder) eatString() error {
-	for len(dec.buf[dec.pos:]) >= 8 {
+func (dec *Decoder) eatString(quo byte) error {
+	for quo == '"' && len(dec.buf[dec.pos:]) >= 8 {
 		unesc := arith.Escape(lit.Uint64(dec.buf[dec.pos:]))
 		dec.pos += unesc
 		if unesc != 8 {
 			break
 		}
 	}
-	if dec.pos < len(dec.buf) && dec.buf[dec.pos] == '"' {
+	if quo == '"' && dec.pos < len(dec.buf) && dec.buf[dec.pos] == '"' {
 		dec.pos++
 		return nil
 	}
-	return dec.eatEscape()
+	return dec.eatEscape(quo)
 }
 
-func (dec *Decoder) eatEscape() error {
+func (dec *Decoder) eatEscape(quo byte) error {
 	const pref = "\\u"
 	var esc bool
 	for dec.pos < len(dec.buf) || dec.fill() {
@@ -221,7 +380,7 @@ func (dec *Decoder) eatEscape() error {
 			}
 			dec.pos++
 			if !dec.makeSpace(4) {
-				return dec.errSyntax("not enough space to create Unicode code point")
+				return dec.errEOF("not enough space to create Unicode code point")
 			}
 			one, err := dec.hex()
 			if err != nil {
@@ -246,7 +405,7 @@ func (dec *Decoder) eatEscape() error {
 		} else if char == '\\' {
 			esc = true
 			dec.pos++
-		} else if char == '"' {
+		} else if char == quo {
 			dec.pos++
 			return nil
 		} else if char < ' ' {
@@ -269,10 +428,10 @@ func (dec *Decoder) eatEscape() error {
 			dec.pos += lo
 		}
 	}
-	return dec.errSyntax("string literal not terminated")
+	return dec.errEOF("string literal not terminated")
 }
 
-func (dec *Decoder) readEscape(dst []byte) ([]byte, error) {
+func (dec *Decoder) readEscape(dst []byte, quo byte) ([]byte, error) {
 	const pref = "\\u"
 	var esc bool
 	var pos int
@@ -289,7 +448,7 @@ func (dec *Decoder) readEscape(dst []byte) ([]byte, error) {
 			}
 			dec.pos++
 			if !dec.makeSpace(4) {
-				return nil, dec.errSyntax("not enough space to create Unicode code point")
+				return nil, dec.errEOF("not enough space to create Unicode code point")
 			}
 			one, err := dec.hex()
 			if err != nil {
@@ -319,7 +478,7 @@ func (dec *Decoder) readEscape(dst []byte) ([]byte, error) {
 			dst = append(dst, dec.buf[dec.pos:dec.pos+pos]...)
 			dec.pos += pos + 1
 			pos = 0
-		} else if char == '"' {
+		} else if char == quo {
 			dst = append(dst, dec.buf[dec.pos:dec.pos+pos]...)
 			dec.pos += pos + 1
 			pos = 0
@@ -350,31 +509,36 @@ func (dec *Decoder) readEscape(dst []byte) ([]byte, error) {
 			pos += lo
 		}
 	}
-	return nil, dec.errSyntax("string literal not terminated")
+	return nil, dec.errEOF("string literal not terminated")
 }
 
 func (dec *Decoder) makeSpace(off int) bool {
-	return dec.pos+off <= len(dec.buf) || dec.fill() && dec.pos+off <= len(dec.buf)
+	for dec.pos+off > len(dec.buf) {
+		if !dec.fill() {
+			return false
+		}
+	}
+	return true
 }
 
-func (dec *Decoder) readString() ([]byte, error) {
+func (dec *Decoder) readString(quo byte) ([]byte, error) {
 	var pos int
 	var err error
-	for len(dec.buf[dec.pos+pos:]) >= 8 {
+	for quo == '"' && len(dec.buf[dec.pos+pos:]) >= 8 {
 		unesc := arith.Escape(lit.Uint64(dec.buf[dec.pos+pos:]))
 		pos += unesc
 		if unesc != 8 {
 			break
 		}
 	}
-	if dec.pos+pos < len(dec.buf) && dec.buf[dec.pos+pos] == '"' {
+	if quo == '"' && dec.pos+pos < len(dec.buf) && dec.buf[dec.pos+pos] == '"' {
 		slice := dec.buf[dec.pos : dec.pos+pos]
 		dec.pos += pos + 1
 		return slice, nil
 	}
 	dec.sub = append(dec.sub[:0], dec.buf[dec.pos:dec.pos+pos]...)
 	dec.pos += pos
-	dec.sub, err = dec.readEscape(dec.sub)
+	dec.sub, err = dec.readEscape(dec.sub, quo)
 	if err != nil {
 		return nil, err
 	}
@@ -400,18 +564,99 @@ func (dec *Decoder) hex() (rune, error) {
 	return run, nil
 }
 
+// isHex reports whether the number starting with head is hexadecimal,
+// like 0x1f or -0X1F. it's only called in the relaxed mode.
+func (dec *Decoder) isHex(head byte) bool {
+	var off int
+	if head == '-' {
+		if !dec.makeSpace(1) || dec.buf[dec.pos] != '0' {
+			return false
+		}
+		off = 1
+	} else if head != '0' {
+		return false
+	}
+	return dec.makeSpace(off+1) && dec.buf[dec.pos+off]|0x20 == 'x'
+}
+
+// readHex reads the hexadecimal number starting with head, see isHex.
+func (dec *Decoder) readHex(head byte) (uint64, bool, error) {
+	neg := head == '-'
+	dec.pos++ // 'x' or 'X'
+	if neg {
+		dec.pos++ // '0'
+	}
+	var u64 uint64
+	var cnt int
+	var over bool
+	for dec.pos < len(dec.buf) || dec.fill() {
+		char := dec.buf[dec.pos]
+		switch {
+		case '0' <= char && char <= '9':
+			char = char - '0'
+		case 'a' <= char && char <= 'f':
+			char = char - 'a' + 10
+		case 'A' <= char && char <= 'F':
+			char = char - 'A' + 10
+		default:
+			char = 0xff
+		}
+		if char == 0xff {
+			break
+		}
+		over = over || u64>>60 != 0
+		u64 = u64<<4 | uint64(char)
+		cnt++
+		dec.pos++
+	}
+	if cnt == 0 {
+		if dec.pos >= len(dec.buf) {
+			return 0, neg, dec.errEOF("unexpected EOF reading a hexadecimal number")
+		}
+		return 0, neg, dec.errSyntax("invalid character " + strconv.QuoteRune(rune(dec.buf[dec.pos])) + " in hexadecimal literal")
+	}
+	if over {
+		return 0, neg, strconv.ErrRange
+	}
+	return u64, neg, nil
+}
+
+// hexFloat returns the hexadecimal number from off to the current position,
+// which readHex found too large for a uint64, as the nearest float64.
+func (dec *Decoder) hexFloat(off int) float64 {
+	flt, _, _ := big.ParseFloat(string(dec.buf[off:dec.pos]), 0, 53, big.ToNearestEven)
+	f64, _ := flt.Float64()
+	return f64
+}
+
 func (dec *Decoder) readFloat() (float64, error) {
+	mant, pow, neg, _, err := dec.readDigits()
+	if err != nil {
+		return 0, err
+	}
+	f64, ok := arith.Lemire64(mant, pow, neg)
+	if !ok {
+		return 0, strconv.ErrRange
+	}
+	return f64, nil
+}
+
+// readDigits reads the number whose head was just read, as a mantissa and a
+// power of ten. exact reports whether it's an integer as written, with neither
+// a fraction nor an exponent.
+func (dec *Decoder) readDigits() (uint64, int64, bool, bool, error) {
 	dec.pos-- // 1 for head.
 	neg := dec.buf[dec.pos] == '-'
 	var mant uint64
 	var pow int64
+	exact := true
 	if neg {
 		dec.pos++
 		if dec.pos >= len(dec.buf) && !dec.fill() {
-			return 0, dec.errSyntax("JSON number ended with '-'")
+			return 0, 0, false, false, dec.errEOF("JSON number ended with '-'")
 		}
 		if dec.buf[dec.pos]-'0' >= 10 {
-			return 0, dec.errSyntax("invalid character " + strconv.QuoteRune(rune(dec.buf[dec.pos])) + " in numeric literal")
+			return 0, 0, false, false, dec.errSyntax("invalid character " + strconv.QuoteRune(rune(dec.buf[dec.pos])) + " in numeric literal")
 		}
 	}
 	if dec.buf[dec.pos] == '0' {
@@ -420,26 +665,28 @@ func (dec *Decoder) readFloat() (float64, error) {
 	} else {
 		u64, read := dec.appendUint(0)
 		if read == -1 {
-			return 0, strconv.ErrRange
+			return 0, 0, false, false, strconv.ErrRange
 		}
 		mant = u64
 	}
 	if dec.pos < len(dec.buf) && dec.buf[dec.pos] == '.' {
+		exact = false
 		dec.pos++
 		if dec.pos >= len(dec.buf) && !dec.fill() {
-			return 0, dec.errSyntax("JSON number ended with '.'")
+			return 0, 0, false, false, dec.errEOF("JSON number ended with '.'")
 		}
 		if dec.buf[dec.pos]-'0' >= 10 {
-			return 0, dec.errSyntax("invalid character " + strconv.QuoteRune(rune(dec.buf[dec.pos])) + " after decimal point in numeric literal")
+			return 0, 0, false, false, dec.errSyntax("invalid character " + strconv.QuoteRune(rune(dec.buf[dec.pos])) + " after decimal point in numeric literal")
 		}
 		u64, read := dec.appendUint(mant)
 		if read == -1 {
-			return 0, strconv.ErrRange
+			return 0, 0, false, false, strconv.ErrRange
 		}
 		mant = u64
 		pow -= int64(read)
 	}
 	if dec.pos < len(dec.buf) && dec.buf[dec.pos]|0x20 == 'e' {
+		exact = false
 		var eneg bool
 		dec.pos++
 		if (dec.pos < len(dec.buf) || dec.fill()) && (dec.buf[dec.pos] == '+' || dec.buf[dec.pos] == '-') {
@@ -447,26 +694,63 @@ func (dec *Decoder) readFloat() (float64, error) {
 			dec.pos++
 		}
 		if dec.pos >= len(dec.buf) && !dec.fill() {
-			return 0, dec.errSyntax("JSON number ended with 'e' or 'E'")
+			return 0, 0, false, false, dec.errEOF("JSON number ended with 'e' or 'E'")
 		}
 		if dec.buf[dec.pos]-'0' >= 10 {
-			return 0, dec.errSyntax("invalid character " + strconv.QuoteRune(rune(dec.buf[dec.pos])) + " in exponent of numeric literal")
+			return 0, 0, false, false, dec.errSyntax("invalid character " + strconv.QuoteRune(rune(dec.buf[dec.pos])) + " in exponent of numeric literal")
 		}
 		enum, read := dec.appendUint(0)
 		if read == -1 {
-			return 0, strconv.ErrRange
+			return 0, 0, false, false, strconv.ErrRange
 		}
 		sign, ok := addSign(enum, eneg)
 		if !ok {
-			return 0, strconv.ErrRange
+			return 0, 0, false, false, strconv.ErrRange
 		}
 		pow += sign
 	}
-	f64, ok := arith.Lemire64(mant, pow, neg)
-	if !ok {
-		return 0, strconv.ErrRange
+	return mant, pow, neg, exact, nil
+}
+
+func (dec *Decoder) isNonFinite(head byte) bool {
+	if dec.opt&optNonFinite == 0 {
+		return false
 	}
-	return f64, nil
+	return len(nonFinites[head]) > 0 || head == '-' && dec.makeSpace(1) && dec.buf[dec.pos] == 'I'
+}
+
+// readNonFinite reads NaN, Infinity or -Infinity. the head must be checked by isNonFinite.
+func (dec *Decoder) readNonFinite(head byte) (float64, error) {
+	sign := 1
+	if head == '-' {
+		sign = -1
+		head = dec.buf[dec.pos]
+		dec.pos++
+	}
+	word := nonFinites[head]
+	part, err := dec.readn(len(word))
+	if err != nil {
+		return 0, err
+	}
+	if string(part) != word {
+		return 0, dec.buildErrSyntax(head, word, part)
+	}
+	if head == 'N' {
+		return math.NaN(), nil
+	}
+	return math.Inf(sign), nil
+}
+
+func parseNonFinite(src []byte) (float64, bool) {
+	switch string(src) {
+	case "NaN":
+		return math.NaN(), true
+	case "Infinity":
+		return math.Inf(1), true
+	case "-Infinity":
+		return math.Inf(-1), true
+	}
+	return 0, false
 }
 
 func (dec *Decoder) eatNumber() error {
@@ -474,7 +758,7 @@ func (dec *Decoder) eatNumber() error {
 	if dec.buf[dec.pos] == '-' {
 		dec.pos++
 		if dec.pos >= len(dec.buf) && !dec.fill() {
-			return dec.errSyntax("JSON number ended with '-'")
+			return dec.errEOF("JSON number ended with '-'")
 		}
 		if dec.buf[dec.pos]-'0' >= 10 {
 			return dec.errSyntax("invalid character " + strconv.QuoteRune(rune(dec.buf[dec.pos])) + " in numeric literal")
@@ -493,7 +777,7 @@ func (dec *Decoder) eatNumber() error {
 	if dec.buf[dec.pos] == '.' {
 		dec.pos++ // the decimal point
 		if dec.pos >= len(dec.buf) && !dec.fill() {
-			return dec.errSyntax("JSON number ended with '.'")
+			return dec.errEOF("JSON number ended with '.'")
 		}
 		if dec.buf[dec.pos]-'0' >= 10 {
 			return dec.errSyntax("invalid character " + strconv.QuoteRune(rune(dec.buf[dec.pos])) + " after decimal point in numeric literal")
@@ -510,7 +794,7 @@ func (dec *Decoder) eatNumber() error {
 			dec.pos++
 		}
 		if dec.pos >= len(dec.buf) && !dec.fill() {
-			return dec.errSyntax("JSON number ended with 'e' or 'E'")
+			return dec.errEOF("JSON number ended with 'e' or 'E'")
 		}
 		if dec.buf[dec.pos]-'0' >= 10 {
 			return dec.errSyntax("invalid character " + strconv.QuoteRune(rune(dec.buf[dec.pos])) + " in exponent of numeric literal")
@@ -10,6 +10,10 @@ var (
 		'f': "alse",
 		'n': "ull",
 	}
+	nonFinites = [1 << 8]string{
+		'N': "aN",
+		'I': "nfinity",
+	}
 )
 
 func (dec *Decoder) buildErrSyntax(head byte, exp string, got []byte) error {
@@ -38,8 +42,8 @@ func (dec *Decoder) skip(head byte) error {
 	if head == '[' {
 		return dec.skipArray(false)
 	}
-	if head == '"' {
-		return dec.eatString()
+	if dec.isQuote(head) {
+		return dec.eatString(head)
 	}
 	keyword := keywords[head]
 	if len(keyword) > 0 {
@@ -53,7 +57,18 @@ func (dec *Decoder) skip(head byte) error {
 		}
 		return nil
 	}
+	if dec.isNonFinite(head) {
+		_, err := dec.readNonFinite(head)
+		return err
+	}
 	if head-'0' < 10 || head == '-' {
+		if dec.opt&optRelaxed != 0 && dec.isHex(head) {
+			_, _, err := dec.readHex(head)
+			if err == strconv.ErrRange {
+				err = nil // not an error for skipping.
+			}
+			return err
+		}
 		return dec.eatNumber()
 	}
 	return dec.errSyntax("invalid character " + strconv.QuoteRune(rune(head)) + " looking for beginning of value")
@@ -67,11 +82,11 @@ func (dec *Decoder) skipArray(mid bool) error {
 	for {
 		dec.eatSpaces()
 		if dec.pos >= len(dec.buf) && !dec.fill() {
-			return dec.errSyntax("unexpected EOF reading a byte")
+			return dec.errEOF("unexpected EOF reading a byte")
 		}
 		head := dec.buf[dec.pos]
 		dec.pos++
-		if head == ']' && !mid {
+		if head == ']' && (!mid || dec.opt&optRelaxed != 0) {
 			dec.dep--
 			return nil
 		}
@@ -83,7 +98,7 @@ func (dec *Decoder) skipArray(mid bool) error {
 
 		dec.eatSpaces()
 		if dec.pos >= len(dec.buf) && !dec.fill() {
-			return dec.errSyntax("unexpected EOF reading a byte")
+			return dec.errEOF("unexpected EOF reading a byte")
 		}
 		head = dec.buf[dec.pos]
 		dec.pos++
@@ -107,25 +122,26 @@ func (dec *Decoder) skipObject() error {
 	for {
 		dec.eatSpaces()
 		if dec.pos >= len(dec.buf) && !dec.fill() {
-			return dec.errSyntax("unexpected EOF reading a byte")
+			return dec.errEOF("unexpected EOF reading a byte")
 		}
 		head := dec.buf[dec.pos]
 		dec.pos++
-		if head == '}' && !mid {
+		if head == '}' && (!mid || dec.opt&optRelaxed != 0) {
 			dec.dep--
 			return nil
 		}
-		if head != '"' {
-			return dec.errSyntax("invalid character " + strconv.QuoteRune(rune(head)) + " looking for beginning of object key string")
+		if head == '"' {
+			err = dec.eatString(head)
+		} else {
+			_, err = dec.readKey(head)
 		}
-		err = dec.eatString()
 		if err != nil {
 			return err
 		}
 
 		dec.eatSpaces()
 		if dec.pos >= len(dec.buf) && !dec.fill() {
-			return dec.errSyntax("unexpected EOF reading a byte")
+			return dec.errEOF("unexpected EOF reading a byte")
 		}
 		head = dec.buf[dec.pos]
 		dec.pos++
@@ -135,7 +151,7 @@ func (dec *Decoder) skipObject() error {
 
 		dec.eatSpaces()
 		if dec.pos >= len(dec.buf) && !dec.fill() {
-			return dec.errSyntax("unexpected EOF reading a byte")
+			return dec.errEOF("unexpected EOF reading a byte")
 		}
 		head = dec.buf[dec.pos]
 		dec.pos++
@@ -147,7 +163,7 @@ func (dec *Decoder) skipObject() error {
 
 		dec.eatSpaces()
 		if dec.pos >= len(dec.buf) && !dec.fill() {
-			return dec.errSyntax("unexpected EOF reading a byte")
+			return dec.errEOF("unexpected EOF reading a byte")
 		}
 		head = dec.buf[dec.pos]
 		dec.pos++
@@ -19,6 +19,17 @@ func (slice *sliceValue) clearTo(to int) {
 	slice.SetLen(slice.Cap())
 }
 
+// copyTo sets val to the first leng elements of slice, reusing the
+// backing array of val when it's large enough, and clears them in slice.
+func (slice *sliceValue) copyTo(val reflect.Value, leng int) {
+	if leng > val.Cap() {
+		val.Set(reflect.MakeSlice(val.Type(), leng, leng))
+	}
+	val.SetLen(leng) // make sure Copy stops at leng.
+	reflect.Copy(val, slice.Value)
+	slice.clearTo(leng)
+}
+
 func compileSliceDecoder(typ reflect.Type) decoder {
 	elm := typ.Elem()
 	fnc, ok := decs.get(elm)
@@ -62,7 +73,7 @@ func compileSliceDecoder(typ reflect.Type) decoder {
 		for idx := 0; ; idx++ {
 			dec.eatSpaces()
 			if dec.pos >= len(dec.buf) && !dec.fill() {
-				return dec.errSyntax("unexpected EOF reading a byte")
+				return dec.errEOF("unexpected EOF reading a byte")
 			}
 			head = dec.buf[dec.pos]
 			dec.pos++
@@ -78,6 +89,13 @@ func compileSliceDecoder(typ reflect.Type) decoder {
 				dec.dep--
 				return nil
 			}
+			if head == ']' && dec.opt&optRelaxed != 0 {
+				// after a trailing comma.
+				assign.copyTo(val, idx)
+				pool.Put(assign)
+				dec.dep--
+				return nil
+			}
 			if idx >= assign.Cap() {
 				assign.Grow(1)              // slices grow one by one, no need to calc.
 				assign.SetLen(assign.Cap()) // make sure grown capacity exists as len.
@@ -88,18 +106,12 @@ func compileSliceDecoder(typ reflect.Type) decoder {
 			}
 			dec.eatSpaces()
 			if dec.pos >= len(dec.buf) && !dec.fill() {
-				return dec.errSyntax("unexpected EOF reading a byte")
+				return dec.errEOF("unexpected EOF reading a byte")
 			}
 			head = dec.buf[dec.pos]
 			dec.pos++
 			if head == ']' {
-				leng := idx + 1
-				if idx >= val.Cap() {
-					val.Set(reflect.MakeSlice(val.Type(), leng, leng))
-				}
-				val.SetLen(leng) // make sure Copy stops at idx+1.
-				reflect.Copy(val, assign.Value)
-				assign.clearTo(leng)
+				assign.copyTo(val, idx+1)
 				pool.Put(assign)
 				dec.dep--
 				return nil
@@ -124,10 +136,10 @@ func compileSliceDecoder(typ reflect.Type) decoder {
 				val.SetZero()
 				return nil
 			}
-			if head != '"' {
+			if !dec.isQuote(head) {
 				return fallback(head, val, dec)
 			}
-			slice, err := dec.readString()
+			slice, err := dec.readString(head)
 			if err != nil {
 				return err
 			}
@@ -0,0 +1,79 @@
+package sonnet
+
+import (
+	"bufio"
+	"io"
+)
+
+// CompactStream is like Compact but reads the JSON from inp and writes the
+// result to out as it goes, so memory use doesn't grow with the size of the
+// input, only with the longest string or number in it. The input may hold
+// several values, which are written one per line. On error, part of the
+// result may have been written to out.
+func CompactStream(out io.Writer, inp io.Reader) error {
+	return reformat(out, inp, IndentOptions{})
+}
+
+// IndentStream is like Indent but reads the JSON from inp and writes the
+// result to out as it goes, the same way CompactStream does.
+func IndentStream(out io.Writer, inp io.Reader, prefix, indent string) error {
+	return reformat(out, inp, DefaultIndentOptions(prefix, indent))
+}
+
+// ValidReader reports whether inp holds one or more valid JSON values,
+// separated by optional whitespace. It reads the input to the end, or up to
+// the first error, keeping only a small part of it in memory.
+// A read error other than io.EOF makes it report false.
+func ValidReader(inp io.Reader) bool {
+	dec := NewDecoder(inp)
+	defer dec.Release()
+	for cnt := 0; ; cnt++ {
+		err := dec.SkipValue()
+		if err == io.EOF {
+			return cnt > 0
+		}
+		if err != nil {
+			return false
+		}
+	}
+}
+
+// reformat copies the values in inp to out token by token, laid out as
+// opts tells. Each token is checked and written by the Encoder, which runs
+// the compactor over it.
+func reformat(out io.Writer, inp io.Reader, opts IndentOptions) error {
+	wrt := bufio.NewWriter(out)
+	dec := NewDecoder(inp)
+	defer dec.Release()
+	enc := NewEncoder(wrt)
+	enc.SetEscapeHTML(false)
+	enc.SetIndentOptions(opts)
+	for {
+		head, _, err := dec.step()
+		if err == io.EOF && !enc.more {
+			return dec.errSyntax("unexpected EOF reading a byte")
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if head == '{' || head == '}' || head == '[' || head == ']' {
+			err = enc.WriteToken(Delim(head))
+		} else {
+			off := dec.pos - 1 // include the head.
+			dec.opt |= optKeep
+			err = dec.skip(head)
+			dec.opt &^= optKeep
+			if err != nil {
+				return err
+			}
+			err = enc.WriteValue(dec.buf[off:dec.pos])
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return wrt.Flush()
+}
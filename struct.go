@@ -21,6 +21,15 @@ func compileStructDecoder(typ reflect.Type) decoder {
 				decs.set(flw, fld.dec)
 			}
 		}
+		if flds.unk != nil {
+			elm := flds.unk.typ.Elem()
+			fnc, ok := decs.get(elm)
+			if !ok {
+				fnc = compileDecoder(elm)
+				decs.set(elm, fnc)
+			}
+			flds.unk.dec = fnc
+		}
 	}
 	var buf []byte
 	var atom atomic.Bool
@@ -47,19 +56,16 @@ func compileStructDecoder(typ reflect.Type) decoder {
 		for {
 			dec.eatSpaces()
 			if dec.pos >= len(dec.buf) && !dec.fill() {
-				return dec.errSyntax("unexpected EOF reading a byte")
+				return dec.errEOF("unexpected EOF reading a byte")
 			}
 			head = dec.buf[dec.pos]
 			dec.pos++
-			if head == '}' && !mid {
+			if head == '}' && (!mid || dec.opt&optRelaxed != 0) {
 				dec.dep--
 				return nil
 			}
-			if head != '"' {
-				return dec.errSyntax("invalid character " + strconv.QuoteRune(rune(head)) + " looking for beginning of object key string")
-			}
 
-			slice, err := dec.readString()
+			slice, err := dec.readKey(head)
 			if err != nil {
 				return err
 			}
@@ -103,7 +109,7 @@ func compileStructDecoder(typ reflect.Type) decoder {
 
 				dec.eatSpaces()
 				if dec.pos >= len(dec.buf) && !dec.fill() {
-					return dec.errSyntax("unexpected EOF reading a byte")
+					return dec.errEOF("unexpected EOF reading a byte")
 				}
 				head = dec.buf[dec.pos]
 				dec.pos++
@@ -113,26 +119,24 @@ func compileStructDecoder(typ reflect.Type) decoder {
 
 				dec.eatSpaces()
 				if dec.pos >= len(dec.buf) && !dec.fill() {
-					return dec.errSyntax("unexpected EOF reading a byte")
+					return dec.errEOF("unexpected EOF reading a byte")
 				}
 				head = dec.buf[dec.pos]
 				dec.pos++
 
 				if fld.flg&flagString != 0 && head != 'n' {
 					const tmpl = "invalid use of ,string struct tag, trying to unmarshal "
-					if head != '"' {
+					if !dec.isQuote(head) {
 						return fieldError(tmpl + "unquoted value" + " into " + flw.Type().String())
 					}
 
-					slice, err := dec.readString()
+					off := dec.InputOffset()
+					slice, err := dec.readString(head)
 					if err != nil {
 						return err
 					}
 
-					temp := Decoder{
-						buf:  slice,
-						prev: dec.prev + dec.pos - len(slice),
-					}
+					temp := dec.subDecoder(slice, off)
 
 					temp.eatSpaces()
 					if len(temp.buf) <= temp.pos {
@@ -147,6 +151,15 @@ func compileStructDecoder(typ reflect.Type) decoder {
 						err = fieldError(tmpl + strconv.Quote(string(slice)) + " into " + flw.Type().String())
 						return err
 					}
+				} else if fld.flg&flagLenient != 0 && dec.isQuote(head) {
+					err = dec.decodeQuoted(head, flw, fld.dec)
+					if err != nil {
+						if err, ok := err.(*UnmarshalTypeError); ok {
+							err.Struct = typ.Name()
+							err.Field = fld.name
+						}
+						return err
+					}
 				} else {
 					err = fld.dec(head, flw, dec)
 					if err != nil {
@@ -162,13 +175,18 @@ func compileStructDecoder(typ reflect.Type) decoder {
 						return err
 					}
 				}
+			} else if flds.unk != nil {
+				err = dec.decodeUnknown(string(slice), val, flds.unk)
+				if err != nil {
+					return err
+				}
 			} else if dec.opt&optUnknownFields != 0 {
 				const tmpl = "unknown field "
 				return fieldError(tmpl + strconv.Quote(string(slice)))
 			} else {
 				dec.eatSpaces()
 				if dec.pos >= len(dec.buf) && !dec.fill() {
-					return dec.errSyntax("unexpected EOF reading a byte")
+					return dec.errEOF("unexpected EOF reading a byte")
 				}
 				head = dec.buf[dec.pos]
 				dec.pos++
@@ -178,7 +196,7 @@ func compileStructDecoder(typ reflect.Type) decoder {
 
 				dec.eatSpaces()
 				if dec.pos >= len(dec.buf) && !dec.fill() {
-					return dec.errSyntax("unexpected EOF reading a byte")
+					return dec.errEOF("unexpected EOF reading a byte")
 				}
 				head = dec.buf[dec.pos]
 				dec.pos++
@@ -191,7 +209,7 @@ func compileStructDecoder(typ reflect.Type) decoder {
 
 			dec.eatSpaces()
 			if dec.pos >= len(dec.buf) && !dec.fill() {
-				return dec.errSyntax("unexpected EOF reading a byte")
+				return dec.errEOF("unexpected EOF reading a byte")
 			}
 			head = dec.buf[dec.pos]
 			dec.pos++
@@ -209,6 +227,11 @@ func compileStructDecoder(typ reflect.Type) decoder {
 
 func compileStructEncoder(typ reflect.Type) encoder {
 	flds := makeFields(typ)
+	if flds.err != nil {
+		return func(dst []byte, val reflect.Value, enc *Encoder) ([]byte, error) {
+			return dst, flds.err
+		}
+	}
 
 	rep := func() {
 		for idx := range flds.flds {
@@ -219,6 +242,15 @@ func compileStructEncoder(typ reflect.Type) encoder {
 				encs.set(flw, fld.enc)
 			}
 		}
+		if flds.unk != nil {
+			elm := flds.unk.typ.Elem()
+			fnc, ok := encs.get(elm)
+			if !ok {
+				fnc = compileEncoder(elm, true)
+				encs.set(elm, fnc)
+			}
+			flds.unk.enc = fnc
+		}
 	}
 	var buf []byte
 	var atom atomic.Bool
@@ -262,12 +294,16 @@ func compileStructEncoder(typ reflect.Type) encoder {
 					if err != nil {
 						return nil, err
 					}
-					if flw.Kind() != reflect.String {
+					if flw.Kind() == reflect.String {
+						dst = appendString(dst, string(buf), false)
+					} else if len(buf) > 0 && buf[0] == '"' || string(buf) == "null" {
+						// already quoted, as NonFiniteString does,
+						// or null, as NonFiniteNull does.
+						dst = append(dst, buf...)
+					} else {
 						dst = append(dst, '"')
 						dst = append(dst, buf...)
 						dst = append(dst, '"')
-					} else {
-						dst = appendString(dst, string(buf), false)
 					}
 					atom.Store(false)
 				} else {
@@ -275,12 +311,16 @@ func compileStructEncoder(typ reflect.Type) encoder {
 					if err != nil {
 						return nil, err
 					}
-					if flw.Kind() != reflect.String {
+					if flw.Kind() == reflect.String {
+						dst = appendString(dst, string(buf), false)
+					} else if len(buf) > 0 && buf[0] == '"' || string(buf) == "null" {
+						// already quoted, as NonFiniteString does,
+						// or null, as NonFiniteNull does.
+						dst = append(dst, buf...)
+					} else {
 						dst = append(dst, '"')
 						dst = append(dst, buf...)
 						dst = append(dst, '"')
-					} else {
-						dst = appendString(dst, string(buf), false)
 					}
 				}
 			} else {
@@ -292,10 +332,87 @@ func compileStructEncoder(typ reflect.Type) encoder {
 			}
 			mid = true
 		}
+		if flds.unk != nil {
+			var err error
+			dst, err = appendUnknown(dst, val, &flds, mid, enc)
+			if err != nil {
+				return nil, err
+			}
+		}
 		return append(dst, '}'), nil
 	}
 }
 
+// decodeUnknown reads the value of the unknown member key
+// into the map of unk, which is in the struct val.
+func (dec *Decoder) decodeUnknown(key string, val reflect.Value, unk *field) error {
+	mp, err := followValue(val, unk.idxs)
+	if err != nil {
+		return err
+	}
+
+	dec.eatSpaces()
+	if dec.pos >= len(dec.buf) && !dec.fill() {
+		return dec.errEOF("unexpected EOF reading a byte")
+	}
+	head := dec.buf[dec.pos]
+	dec.pos++
+	if head != ':' {
+		return dec.errSyntax("invalid character " + strconv.QuoteRune(rune(head)) + " after object key")
+	}
+
+	dec.eatSpaces()
+	if dec.pos >= len(dec.buf) && !dec.fill() {
+		return dec.errEOF("unexpected EOF reading a byte")
+	}
+	head = dec.buf[dec.pos]
+	dec.pos++
+
+	if mp.IsNil() {
+		mp.Set(reflect.MakeMap(unk.typ))
+	}
+	elm := reflect.New(unk.typ.Elem()).Elem()
+	err = unk.dec(head, elm, dec)
+	if err != nil {
+		return err
+	}
+	mp.SetMapIndex(reflect.ValueOf(key).Convert(unk.typ.Key()), elm)
+	return nil
+}
+
+// appendUnknown writes the members of the map of unk, which is in the struct val.
+// keys that belong to a field are left out, the field wins.
+func appendUnknown(dst []byte, val reflect.Value, flds *fields, mid bool, enc *Encoder) ([]byte, error) {
+	mp := val
+	for _, idx := range flds.unk.idxs {
+		if mp.Kind() == reflect.Pointer {
+			if mp.IsNil() {
+				return dst, nil
+			}
+			mp = mp.Elem()
+		}
+		mp = mp.Field(idx)
+	}
+	if mp.Len() == 0 {
+		return dst, nil
+	}
+	// the members go through the same steps as those of a map field.
+	prs := make([]*pair, 0, mp.Len())
+	rng := mp.MapRange()
+	for rng.Next() {
+		str := rng.Key().String()
+		hash := hash32([]byte(str), flds.fldsMap.seed)
+		if tup := flds.fldsMap.tups[hash&flds.fldsMap.mask]; tup.hash == hash && tup.elm.name == str {
+			continue
+		}
+		prs = append(prs, &pair{str: str, key: rng.Key(), elm: rng.Value()})
+	}
+	if !enc.opts.UnorderedMaps {
+		sortPairs(prs, false)
+	}
+	return appendPairs(dst, prs, false, mid, flds.unk.enc, enc)
+}
+
 func appendUpper(dst []byte, src []byte) []byte {
 	const lenAZ = 'Z' - 'A'
 	var idx int
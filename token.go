@@ -1,5 +1,11 @@
 package sonnet
 
+import (
+	"io"
+	"reflect"
+	"strconv"
+)
+
 type (
 	// A Token holds a value of one of these types:
 	//
@@ -12,6 +18,54 @@ type (
 	Token any
 	// A Delim is a JSON array or object delimiter, one of [ ] { or }.
 	Delim rune
+	// A Kind is the kind of a RawToken.
+	Kind byte
+	// A RawToken is a token returned by ReadToken. Unlike Token, it holds
+	// the text of the token, which is converted only when asked for.
+	RawToken struct {
+		Kind Kind
+		// Raw is the contents of a string, with escape sequences decoded,
+		// or the text of any other token, such as 1.5e3, true or {.
+		Raw []byte
+	}
+)
+
+// The states of the token grammar, as in encoding/json.
+const (
+	tokenTopValue = iota
+	tokenArrayStart
+	tokenArrayValue
+	tokenArrayComma
+	tokenObjectStart
+	tokenObjectKey
+	tokenObjectColon
+	tokenObjectValue
+	tokenObjectComma
+)
+
+// The kinds of tokens. KindObject and KindArray are the opening delimiters.
+const (
+	KindInvalid Kind = iota
+	KindNull
+	KindBool
+	KindString
+	KindNumber
+	KindObject
+	KindObjectEnd
+	KindArray
+	KindArrayEnd
+)
+
+var (
+	kinds = [1 << 8]Kind{
+		'n': KindNull,
+		't': KindBool,
+		'f': KindBool,
+		'{': KindObject,
+		'}': KindObjectEnd,
+		'[': KindArray,
+		']': KindArrayEnd,
+	}
 )
 
 func (delim Delim) String() string {
@@ -30,18 +84,441 @@ func (delim Delim) String() string {
 // to mark the start and end of arrays and objects.
 // Commas and colons are elided.
 func (dec *Decoder) Token() (Token, error) {
+	head, key, err := dec.step()
+	if err != nil {
+		return nil, err
+	}
+	if head == '{' || head == '}' || head == '[' || head == ']' {
+		return Delim(head), nil
+	}
+	if key {
+		str, err := dec.readKey(head)
+		if err != nil {
+			return nil, err
+		}
+		dec.member(str)
+		return string(str), nil
+	}
+	tok, err := dec.readAny(head)
+	if err == nil {
+		err = dec.errCut(head)
+	}
+	return tok, err
+}
+
+// step reads the first byte of the next token, consuming the commas and
+// colons before it, and moves the grammar state past the token.
+// key reports whether the token is an object key.
+func (dec *Decoder) step() (byte, bool, error) {
 	for {
 		dec.eatSpaces()
 		if dec.pos >= len(dec.buf) && !dec.fill() {
-			return nil, dec.errSyntax("unexpected EOF reading a byte")
+			if dec.err == io.EOF && dec.state == tokenTopValue {
+				return 0, false, io.EOF
+			}
+			return 0, false, dec.errEOF("unexpected EOF reading a byte")
+		}
+		head := dec.buf[dec.pos]
+		relaxed := dec.opt&optRelaxed != 0
+		switch {
+		case head == ',' && dec.state == tokenArrayComma:
+			dec.state = tokenArrayValue
+		case head == ',' && dec.state == tokenObjectComma:
+			dec.state = tokenObjectKey
+		case head == ':' && dec.state == tokenObjectColon:
+			dec.state = tokenObjectValue
+		case head == ']' && (dec.state == tokenArrayStart || dec.state == tokenArrayComma || relaxed && dec.state == tokenArrayValue),
+			head == '}' && (dec.state == tokenObjectStart || dec.state == tokenObjectComma || relaxed && dec.state == tokenObjectKey):
+			dec.pos++
+			dec.dep--
+			dec.state = dec.stack[len(dec.stack)-1].prev
+			dec.stack = dec.stack[:len(dec.stack)-1]
+			dec.valueEnd()
+			return head, false, nil
+		case dec.state == tokenObjectStart || dec.state == tokenObjectKey:
+			if !dec.isQuote(head) && (!relaxed || !isIdent(head)) {
+				return 0, false, dec.errToken(head)
+			}
+			dec.pos++
+			dec.state = tokenObjectColon
+			return head, true, nil
+		case !dec.valueAllowed() || head == ',' || head == ':' || head == ']' || head == '}':
+			return 0, false, dec.errToken(head)
+		case head == '[' || head == '{':
+			err := dec.inc()
+			if err != nil {
+				return 0, false, err
+			}
+			dec.pos++
+			dec.begin()
+			dec.push(kinds[head])
+			return head, false, nil
+		default:
+			dec.pos++
+			dec.begin()
+			dec.valueEnd()
+			return head, false, nil
+		}
+		dec.pos++
+	}
+}
+
+// prepare consumes the comma or the colon before a value that Decode reads
+// in the middle of a token stream.
+func (dec *Decoder) prepare() error {
+	if dec.state == tokenArrayComma || dec.state == tokenObjectColon {
+		dec.eatSpaces()
+		if dec.pos >= len(dec.buf) && !dec.fill() {
+			return dec.errEOF("unexpected EOF reading a byte")
 		}
 		head := dec.buf[dec.pos]
+		if dec.state == tokenArrayComma {
+			if head != ',' {
+				return dec.errToken(head)
+			}
+			dec.state = tokenArrayValue
+		} else {
+			if head != ':' {
+				return dec.errToken(head)
+			}
+			dec.state = tokenObjectValue
+		}
 		dec.pos++
-		if head == '{' || head == '}' || head == '[' || head == ']' {
-			return Delim(head), nil
+	}
+	if !dec.valueAllowed() {
+		return dec.errSyntax("not at beginning of value")
+	}
+	dec.begin()
+	return nil
+}
+
+// push enters an array or an object.
+func (dec *Decoder) push(kind Kind) {
+	if len(dec.stack) < cap(dec.stack) {
+		// keep the buffer of the key.
+		dec.stack = dec.stack[:len(dec.stack)+1]
+	} else {
+		dec.stack = append(dec.stack, frame{})
+	}
+	top := &dec.stack[len(dec.stack)-1]
+	top.kind, top.prev, top.cnt = kind, dec.state, 0
+	top.key = top.key[:0]
+	dec.state = tokenArrayStart
+	if kind == KindObject {
+		dec.state = tokenObjectStart
+	}
+}
+
+// begin counts the value about to be read if it's an array element.
+func (dec *Decoder) begin() {
+	if dec.state == tokenArrayStart || dec.state == tokenArrayValue {
+		dec.stack[len(dec.stack)-1].cnt++
+	}
+}
+
+// member records the object key just read.
+func (dec *Decoder) member(key []byte) {
+	top := &dec.stack[len(dec.stack)-1]
+	top.cnt++
+	top.key = append(top.key[:0], key...)
+}
+
+// StackDepth returns the number of arrays and objects that the tokens read
+// so far are in. It is 0 between top-level values.
+// Only Token and ReadToken keep track of the stack: a value read whole by
+// Decode, SkipValue or ReadValue counts as one element or member, and the
+// arrays and objects inside it never show up in StackDepth, StackIndex or
+// StackPointer, even while it is being decoded.
+func (dec *Decoder) StackDepth() int {
+	return len(dec.stack)
+}
+
+// StackIndex describes the array or object at the given depth, where 0 is
+// the outermost one and StackDepth()-1 the innermost. It returns KindArray
+// or KindObject, and the number of elements or members read so far, counting
+// the one being read. It returns KindInvalid and 0 for other depths.
+func (dec *Decoder) StackIndex(idx int) (Kind, int) {
+	if idx < 0 || idx >= len(dec.stack) {
+		return KindInvalid, 0
+	}
+	return dec.stack[idx].kind, dec.stack[idx].cnt
+}
+
+// StackPointer returns the location of the last value read or being read as
+// a JSON Pointer (RFC 6901), such as "/users/0/name". Arrays and objects
+// without elements or members so far end the pointer.
+func (dec *Decoder) StackPointer() string {
+	var ptr []byte
+	for idx := range dec.stack {
+		frm := &dec.stack[idx]
+		if frm.cnt == 0 {
+			break
+		}
+		ptr = append(ptr, '/')
+		if frm.kind == KindArray {
+			ptr = strconv.AppendInt(ptr, int64(frm.cnt-1), 10)
+			continue
 		}
-		if head != ',' && head != ':' {
-			return dec.readAny(head)
+		for _, char := range frm.key {
+			switch char {
+			case '~':
+				ptr = append(ptr, '~', '0')
+			case '/':
+				ptr = append(ptr, '~', '1')
+			default:
+				ptr = append(ptr, char)
+			}
 		}
 	}
+	return string(ptr)
+}
+
+func (dec *Decoder) valueAllowed() bool {
+	switch dec.state {
+	case tokenTopValue, tokenArrayStart, tokenArrayValue, tokenObjectValue:
+		return true
+	}
+	return false
+}
+
+func (dec *Decoder) valueEnd() {
+	switch dec.state {
+	case tokenArrayStart, tokenArrayValue:
+		dec.state = tokenArrayComma
+	case tokenObjectValue:
+		dec.state = tokenObjectComma
+	}
+}
+
+func (dec *Decoder) errToken(head byte) error {
+	var ctx string
+	switch dec.state {
+	case tokenArrayComma:
+		ctx = " after array element"
+	case tokenObjectStart, tokenObjectKey:
+		ctx = " looking for beginning of object key string"
+	case tokenObjectColon:
+		ctx = " after object key"
+	case tokenObjectComma:
+		ctx = " after object key:value pair"
+	default:
+		ctx = " looking for beginning of value"
+	}
+	return &SyntaxError{
+		msg:    "invalid character " + strconv.QuoteRune(rune(head)) + ctx,
+		Offset: dec.InputOffset() + 1,
+	}
+}
+
+// PeekKind returns the kind of the next token without reading it.
+// It returns KindInvalid at the end of the input, when reading fails,
+// or when the next byte can't begin a token; ReadToken then reports why.
+func (dec *Decoder) PeekKind() Kind {
+	for {
+		dec.eatSpaces()
+		if dec.pos >= len(dec.buf) && !dec.fill() {
+			return KindInvalid
+		}
+		head := dec.buf[dec.pos]
+		switch {
+		case head == ',' && dec.state == tokenArrayComma:
+			dec.state = tokenArrayValue
+		case head == ',' && dec.state == tokenObjectComma:
+			dec.state = tokenObjectKey
+		case head == ':' && dec.state == tokenObjectColon:
+			dec.state = tokenObjectValue
+		case head == ',' || head == ':':
+			return KindInvalid
+		case dec.state == tokenObjectStart || dec.state == tokenObjectKey:
+			if dec.opt&optRelaxed != 0 && isIdent(head) {
+				return KindString
+			}
+			fallthrough
+		default:
+			return dec.kindOf(head)
+		}
+		dec.pos++
+	}
+}
+
+// ReadToken returns the next token in the input stream without
+// converting it to a Go value. Like Token, it checks that the tokens
+// form valid JSON, elides commas and colons, and returns io.EOF
+// at the end of the input stream.
+// The Raw field of the token aliases the buffer of the decoder,
+// and is only valid until the next call to the decoder.
+func (dec *Decoder) ReadToken() (RawToken, error) {
+	head, key, err := dec.step()
+	if err != nil {
+		return RawToken{}, err
+	}
+	if key {
+		str, err := dec.readKey(head)
+		if err == nil {
+			dec.member(str)
+		}
+		return RawToken{Kind: KindString, Raw: str}, err
+	}
+	kind := dec.kindOf(head)
+	switch kind {
+	case KindString:
+		str, err := dec.readString(head)
+		return RawToken{Kind: kind, Raw: str}, err
+	case KindBool, KindNull:
+		word := keywords[head]
+		dec.opt |= optKeep
+		off := dec.pos - 1 // include the head.
+		part, err := dec.readn(len(word))
+		dec.opt &^= optKeep
+		if err == nil && string(part) != word {
+			err = dec.buildErrSyntax(head, word, part)
+		}
+		return RawToken{Kind: kind, Raw: dec.buf[off:dec.pos]}, err
+	case KindNumber:
+		dec.opt |= optKeep
+		off := dec.pos - 1 // include the head.
+		var err error
+		if dec.isNonFinite(head) {
+			_, err = dec.readNonFinite(head)
+		} else if dec.opt&optRelaxed != 0 && dec.isHex(head) {
+			_, _, err = dec.readHex(head)
+		} else {
+			err = dec.eatNumber()
+		}
+		dec.opt &^= optKeep
+		if err == nil {
+			err = dec.errCut(head)
+		}
+		return RawToken{Kind: kind, Raw: dec.buf[off:dec.pos]}, err
+	case KindInvalid:
+		return RawToken{}, dec.errSyntax("invalid character " + strconv.QuoteRune(rune(head)) + " looking for beginning of value")
+	}
+	return RawToken{Kind: kind, Raw: dec.buf[dec.pos-1 : dec.pos]}, nil
+}
+
+func (dec *Decoder) kindOf(head byte) Kind {
+	if dec.isQuote(head) {
+		return KindString
+	}
+	if head-'0' < 10 || head == '-' || dec.opt&optNonFinite != 0 && len(nonFinites[head]) > 0 {
+		return KindNumber
+	}
+	return kinds[head]
+}
+
+func (kind Kind) String() string {
+	switch kind {
+	case KindNull:
+		return "null"
+	case KindBool:
+		return "bool"
+	case KindString:
+		return "string"
+	case KindNumber:
+		return "number"
+	case KindObject:
+		return "object"
+	case KindObjectEnd:
+		return "end of object"
+	case KindArray:
+		return "array"
+	case KindArrayEnd:
+		return "end of array"
+	}
+	return "invalid"
+}
+
+// String returns the contents of a string token, or the text of any other token.
+func (tok RawToken) String() string {
+	return string(tok.Raw)
+}
+
+// Bool returns the value of a bool token.
+func (tok RawToken) Bool() (bool, error) {
+	if tok.Kind != KindBool {
+		return false, tok.errType(reflect.TypeOf(false))
+	}
+	return tok.Raw[0] == 't', nil
+}
+
+// Int returns the value of a number token that is an integer fitting in an int64.
+func (tok RawToken) Int() (int64, error) {
+	if tok.Kind != KindNumber {
+		return 0, tok.errType(reflect.TypeOf(int64(0)))
+	}
+	tmp := Decoder{buf: tok.Raw, pos: 1}
+	if tmp.isHex(tok.Raw[0]) {
+		u64, neg, err := tmp.readHex(tok.Raw[0])
+		if err == nil {
+			i64, ok := addSign(u64, neg)
+			if ok {
+				return i64, nil
+			}
+			err = strconv.ErrRange
+		}
+		return 0, &strconv.NumError{Func: "Int", Num: string(tok.Raw), Err: err}
+	}
+	i64, err := toInt(tok.Raw)
+	if err != nil {
+		return 0, &strconv.NumError{Func: "Int", Num: string(tok.Raw), Err: err}
+	}
+	return i64, nil
+}
+
+// Uint returns the value of a number token that is an integer fitting in a uint64.
+func (tok RawToken) Uint() (uint64, error) {
+	if tok.Kind != KindNumber {
+		return 0, tok.errType(reflect.TypeOf(uint64(0)))
+	}
+	tmp := Decoder{buf: tok.Raw, pos: 1}
+	if tmp.isHex(tok.Raw[0]) {
+		u64, neg, err := tmp.readHex(tok.Raw[0])
+		if err == nil && neg && u64 != 0 {
+			err = strconv.ErrSyntax
+		}
+		if err != nil {
+			return 0, &strconv.NumError{Func: "Uint", Num: string(tok.Raw), Err: err}
+		}
+		return u64, nil
+	}
+	u64, err := toUint(tok.Raw)
+	if err != nil {
+		return 0, &strconv.NumError{Func: "Uint", Num: string(tok.Raw), Err: err}
+	}
+	return u64, nil
+}
+
+// Float returns the value of a number token as the nearest float64.
+func (tok RawToken) Float() (float64, error) {
+	if tok.Kind != KindNumber {
+		return 0, tok.errType(reflect.TypeOf(float64(0)))
+	}
+	if f64, ok := parseNonFinite(tok.Raw); ok {
+		return f64, nil
+	}
+	tmp := Decoder{buf: tok.Raw, pos: 1}
+	if tmp.isHex(tok.Raw[0]) {
+		u64, neg, err := tmp.readHex(tok.Raw[0])
+		if err != nil {
+			return 0, &strconv.NumError{Func: "Float", Num: string(tok.Raw), Err: err}
+		}
+		if neg {
+			return -float64(u64), nil
+		}
+		return float64(u64), nil
+	}
+	f64, err := tmp.readFloat()
+	if err == nil && tmp.pos == len(tok.Raw) {
+		return f64, nil
+	}
+	// rare, slow path.
+	f64, err = strconv.ParseFloat(string(tok.Raw), 64)
+	if err != nil {
+		return 0, &strconv.NumError{Func: "Float", Num: string(tok.Raw), Err: err.(*strconv.NumError).Err}
+	}
+	return f64, nil
+}
+
+func (tok RawToken) errType(typ reflect.Type) error {
+	return &UnmarshalTypeError{Value: tok.Kind.String(), Type: typ}
 }
@@ -0,0 +1,190 @@
+package sonnet
+
+import (
+	"encoding/binary"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+type (
+	// transcoder turns the input of a Decoder into UTF-8,
+	// after finding out its encoding from the first bytes.
+	transcoder struct {
+		form byte
+		// the input read but not transcoded yet.
+		raw []byte
+		// the offset in the input of the start of the buffer.
+		base int64
+		// whether every position in the buffer is at base.
+		fixed bool
+	}
+)
+
+const (
+	formUnknown = iota
+	formUTF8
+	formUTF16BE
+	formUTF16LE
+	formUTF32BE
+	formUTF32LE
+)
+
+// DetectEncoding causes the Decoder to find out the encoding of its input
+// from the first bytes, as described in RFC 4627 section 3, and to read
+// UTF-16 and UTF-32 input, big or little endian, as if it were UTF-8.
+// A byte order mark at the start is skipped, including the UTF-8 one.
+// Offsets, such as those of errors and InputOffset, count the bytes of the
+// input as it is. Invalid sequences in UTF-16 and UTF-32 input are replaced
+// by U+FFFD, while UTF-8 input is passed through unchanged, as it is without
+// DetectEncoding. Buffered returns the transcoded data.
+// DetectEncoding must be called before the first read from the input.
+func (dec *Decoder) DetectEncoding() {
+	dec.opt |= optDetect
+}
+
+// read reads from the input into dst, which is free space of the buffer.
+func (dec *Decoder) read(dst []byte) (int, error) {
+	if dec.opt&optDetect == 0 {
+		return dec.inp.Read(dst)
+	}
+	if dec.tr == nil {
+		dec.tr = &transcoder{}
+	}
+	tr := dec.tr
+	if tr.form == formUTF8 && len(tr.raw) == 0 {
+		return dec.inp.Read(dst)
+	}
+	// 2 bytes of UTF-16 take up to 3 bytes in UTF-8.
+	lim := len(dst) / 3 * 2
+	if cap(tr.raw) < lim {
+		raw := make([]byte, len(tr.raw), lim)
+		copy(raw, tr.raw)
+		tr.raw = raw
+	}
+	read, err := dec.inp.Read(tr.raw[len(tr.raw):lim])
+	tr.raw = tr.raw[:len(tr.raw)+read]
+	if tr.form == formUnknown {
+		if !tr.detect(err != nil) {
+			return 0, err
+		}
+	}
+	return tr.transcode(dst, err != nil), err
+}
+
+// detect sets the form of the input from its first bytes, and skips the byte
+// order mark. It reports false when it needs more bytes to know.
+func (tr *transcoder) detect(end bool) bool {
+	raw := tr.raw
+	if len(raw) < 4 && !end {
+		// some input, such as a number followed by a newline, is
+		// shorter. the first two bytes are enough for most of UTF-8.
+		if len(raw) < 2 || raw[0] == 0 || raw[1] == 0 || raw[0] >= 0xef {
+			return false
+		}
+	}
+	var bom int
+	switch {
+	case hasPrefix(raw, "\xef\xbb\xbf"):
+		tr.form, bom = formUTF8, 3
+	case hasPrefix(raw, "\x00\x00\xfe\xff"):
+		tr.form, bom = formUTF32BE, 4
+	case hasPrefix(raw, "\xff\xfe\x00\x00"):
+		tr.form, bom = formUTF32LE, 4
+	case hasPrefix(raw, "\xfe\xff"):
+		tr.form, bom = formUTF16BE, 2
+	case hasPrefix(raw, "\xff\xfe"):
+		tr.form, bom = formUTF16LE, 2
+	case len(raw) >= 4 && raw[0] == 0 && raw[1] == 0 && raw[2] == 0:
+		tr.form = formUTF32BE
+	case len(raw) >= 4 && raw[1] == 0 && raw[2] == 0 && raw[3] == 0:
+		tr.form = formUTF32LE
+	case len(raw) >= 2 && raw[0] == 0:
+		tr.form = formUTF16BE
+	case len(raw) >= 2 && raw[1] == 0:
+		tr.form = formUTF16LE
+	default:
+		tr.form = formUTF8
+	}
+	tr.raw = tr.raw[:copy(tr.raw, tr.raw[bom:])]
+	tr.base += int64(bom)
+	return true
+}
+
+// transcode writes the complete characters in tr.raw to dst as UTF-8,
+// and keeps the rest for later. At the end of the input the rest is invalid.
+func (tr *transcoder) transcode(dst []byte, end bool) int {
+	var order binary.ByteOrder = binary.BigEndian
+	switch tr.form {
+	case formUTF8:
+		size := copy(dst, tr.raw)
+		tr.raw = tr.raw[:copy(tr.raw, tr.raw[size:])]
+		return size
+	case formUTF16BE, formUTF32BE:
+	default:
+		order = binary.LittleEndian
+	}
+	wide := tr.form == formUTF32BE || tr.form == formUTF32LE
+	out := dst[:0]
+	var idx int
+loop:
+	for idx < len(tr.raw) {
+		rest := tr.raw[idx:]
+		run, size := utf8.RuneError, len(rest)
+		switch {
+		case wide && len(rest) >= 4:
+			run, size = rune(order.Uint32(rest)), 4
+		case !wide && len(rest) >= 2:
+			run, size = rune(order.Uint16(rest)), 2
+			if !utf16.IsSurrogate(run) {
+				break
+			}
+			if len(rest) >= 4 {
+				if pair := utf16.DecodeRune(run, rune(order.Uint16(rest[2:]))); pair != utf8.RuneError {
+					run, size = pair, 4
+				}
+			} else if !end {
+				break loop // the other half is yet to come.
+			}
+		case !end:
+			break loop
+		}
+		// surrogates and values out of range become U+FFFD.
+		out = utf8.AppendRune(out, run)
+		idx += size
+	}
+	tr.raw = tr.raw[:copy(tr.raw, tr.raw[idx:])]
+	return len(out)
+}
+
+// units returns the number of bytes the UTF-8 in buf took in the input.
+func (tr *transcoder) units(buf []byte) int64 {
+	if tr.fixed {
+		return 0
+	}
+	if tr.form == formUTF8 || tr.form == formUnknown {
+		return int64(len(buf))
+	}
+	var cnt int64
+	for _, char := range buf {
+		if char&0xc0 == 0x80 {
+			continue // not the first byte of a character.
+		}
+		cnt += 2
+		if char >= 0xf0 || tr.form == formUTF32BE || tr.form == formUTF32LE {
+			cnt += 2
+		}
+	}
+	return cnt
+}
+
+// offset returns the offset in the input of the position pos in the buffer.
+func (dec *Decoder) offset(pos int) int64 {
+	if dec.tr == nil {
+		return int64(dec.prev + pos)
+	}
+	return dec.tr.base + dec.tr.units(dec.buf[:pos])
+}
+
+func hasPrefix(raw []byte, pre string) bool {
+	return len(raw) >= len(pre) && string(raw[:len(pre)]) == pre
+}
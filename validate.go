@@ -0,0 +1,133 @@
+package sonnet
+
+import (
+	"io"
+	"strconv"
+	"unicode/utf8"
+)
+
+type (
+	// ValidateOptions holds the checks ValidateWithOptions makes on top of
+	// the JSON grammar. The zero value accepts what Valid accepts.
+	ValidateOptions struct {
+		// StrictUTF8 rejects strings, including object keys, that contain
+		// invalid UTF-8.
+		StrictUTF8 bool
+		// RejectDuplicateKeys rejects objects that have the same key twice.
+		// Keys are compared after escape sequences are decoded.
+		RejectDuplicateKeys bool
+		// MaxDepth rejects arrays and objects nested more than MaxDepth deep.
+		// Zero means no limit other than the one decoding has.
+		MaxDepth int
+	}
+)
+
+// Validate is like Valid but tells why data isn't a valid JSON encoding.
+// A syntax error is returned as a *SyntaxError, with the Path to the
+// value where it was found.
+func Validate(inp []byte) error {
+	return ValidateWithOptions(inp, ValidateOptions{})
+}
+
+// ValidateWithOptions is like Validate but also makes the checks that
+// opts turns on. Their failures are returned as a *SyntaxError as well.
+func ValidateWithOptions(inp []byte, opts ValidateOptions) error {
+	dec := Decoder{
+		buf: inp,
+		err: io.EOF,
+	}
+	// the keys seen in each enclosing array or object, nil for arrays.
+	var keys []map[string]struct{}
+	for {
+		head, key, err := dec.step()
+		if err == io.EOF {
+			return dec.errPath(dec.errSyntax("unexpected EOF reading a byte"))
+		}
+		if err != nil {
+			return dec.errPath(err)
+		}
+		off := dec.pos - 1
+		switch {
+		case head == '{' || head == '[':
+			if opts.MaxDepth > 0 && len(dec.stack) > opts.MaxDepth {
+				return dec.errPath(dec.errSyntax("exceeded max depth " + strconv.Itoa(opts.MaxDepth)))
+			}
+			if opts.RejectDuplicateKeys {
+				keys = append(keys, nil)
+			}
+		case head == '}' || head == ']':
+			if opts.RejectDuplicateKeys {
+				keys = keys[:len(keys)-1]
+			}
+		case key:
+			str, err := dec.readKey(head)
+			if err != nil {
+				return dec.errPath(err)
+			}
+			dec.member(str)
+			if opts.StrictUTF8 {
+				err = dec.checkUTF8(off)
+				if err != nil {
+					return err
+				}
+			}
+			if opts.RejectDuplicateKeys {
+				seen := keys[len(keys)-1]
+				if seen == nil {
+					seen = make(map[string]struct{})
+					keys[len(keys)-1] = seen
+				}
+				if _, ok := seen[string(str)]; ok {
+					return dec.errPath(dec.errSyntax("duplicate object key " + strconv.Quote(string(str))))
+				}
+				seen[string(str)] = struct{}{}
+			}
+		default:
+			err = dec.skip(head)
+			if err != nil {
+				return dec.errPath(err)
+			}
+			if opts.StrictUTF8 && head == '"' {
+				err = dec.checkUTF8(off)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		if dec.state == tokenTopValue {
+			break
+		}
+	}
+	dec.eatSpaces()
+	if dec.pos < len(dec.buf) {
+		return dec.errSyntax("invalid character " + strconv.QuoteRune(rune(dec.buf[dec.pos])) + " after top-level value")
+	}
+	return nil
+}
+
+// checkUTF8 reports the first invalid UTF-8 sequence in the string
+// from off to the current position.
+func (dec *Decoder) checkUTF8(off int) error {
+	str := dec.buf[off:dec.pos]
+	for idx := 0; idx < len(str); {
+		if str[idx] < utf8.RuneSelf {
+			idx++
+			continue
+		}
+		run, size := utf8.DecodeRune(str[idx:])
+		if run == utf8.RuneError && size == 1 {
+			dec.pos = off + idx
+			return dec.errPath(dec.errSyntax("invalid UTF-8 in string"))
+		}
+		idx += size
+	}
+	return nil
+}
+
+// errPath sets the Path of a syntax error to where the decoder is.
+func (dec *Decoder) errPath(err error) error {
+	if syn, ok := err.(*SyntaxError); ok {
+		syn.Path = dec.StackPointer()
+	}
+	return err
+}
@@ -8,6 +8,13 @@ import (
 	"unicode/utf8"
 )
 
+type (
+	floatFormat struct {
+		verb byte
+		prec int
+	}
+)
+
 var (
 	double = [100]uint16{
 		0x3030, 0x3130, 0x3230, 0x3330, 0x3430, 0x3530, 0x3630, 0x3730, 0x3830, 0x3930,
@@ -204,12 +211,12 @@ func fmtInt(u64 uint64, neg bool) []byte {
 	return buf[pos:]
 }
 
-func appendFloat(dst []byte, f64 float64, bit int) ([]byte, error) {
+func appendFloat(dst []byte, f64 float64, bit int, enc *Encoder) ([]byte, error) {
 	if math.IsInf(f64, 0) || math.IsNaN(f64) {
-		return nil, &UnsupportedValueError{
-			Value: reflect.ValueOf(f64),
-			Str:   strconv.FormatFloat(f64, 'g', -1, bit),
-		}
+		return appendNonFinite(dst, f64, bit, enc)
+	}
+	if enc.flt.verb != 0 {
+		return strconv.AppendFloat(dst, f64, enc.flt.verb, enc.flt.prec, bit), nil
 	}
 	abs := math.Abs(f64)
 	format := byte('f')
@@ -230,3 +237,75 @@ func appendFloat(dst []byte, f64 float64, bit int) ([]byte, error) {
 	}
 	return dst, nil
 }
+
+func appendNonFinite(dst []byte, f64 float64, bit int, enc *Encoder) ([]byte, error) {
+	var word string
+	switch {
+	case math.IsNaN(f64):
+		word = "NaN"
+	case f64 > 0:
+		word = "Infinity"
+	default:
+		word = "-Infinity"
+	}
+	switch enc.opts.NonFinite {
+	case NonFiniteNull:
+		return append(dst, "null"...), nil
+	case NonFiniteString:
+		dst = append(dst, '"')
+		dst = append(dst, word...)
+		return append(dst, '"'), nil
+	case NonFiniteLiteral:
+		return append(dst, word...), nil
+	}
+	return nil, &UnsupportedValueError{
+		Value: reflect.ValueOf(f64),
+		Str:   strconv.FormatFloat(f64, 'g', -1, bit),
+	}
+}
+
+// parseFloatFormat parses a format like "%.2f". the verb is one of f, e or g.
+func parseFloatFormat(str string) (floatFormat, bool) {
+	const maxPrec = 1 << 10
+	flt := floatFormat{prec: -1}
+	if len(str) < 2 || str[0] != '%' {
+		return flt, false
+	}
+	flt.verb = str[len(str)-1]
+	if flt.verb != 'f' && flt.verb != 'e' && flt.verb != 'g' {
+		return flt, false
+	}
+	str = str[1 : len(str)-1]
+	if str == "" {
+		return flt, true
+	}
+	if str[0] != '.' || len(str) < 2 {
+		return flt, false
+	}
+	u64, err := makeUint([]byte(str[1:]))
+	if err != nil || u64 > maxPrec {
+		return flt, false
+	}
+	flt.prec = int(u64)
+	return flt, true
+}
+
+func compileFloatFormatEncoder(typ reflect.Type, flt floatFormat) encoder {
+	if typ.Kind() == reflect.Pointer {
+		fnc := compileFloatFormatEncoder(typ.Elem(), flt)
+		return func(dst []byte, val reflect.Value, enc *Encoder) ([]byte, error) {
+			if val.IsNil() {
+				return append(dst, "null"...), nil
+			}
+			return fnc(dst, val.Elem(), enc)
+		}
+	}
+	bit := typ.Bits()
+	return func(dst []byte, val reflect.Value, enc *Encoder) ([]byte, error) {
+		f64 := val.Float()
+		if math.IsInf(f64, 0) || math.IsNaN(f64) {
+			return appendNonFinite(dst, f64, bit, enc)
+		}
+		return strconv.AppendFloat(dst, f64, flt.verb, flt.prec, bit), nil
+	}
+}